@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -10,7 +11,9 @@ import (
 	"os"
 	"vector-ai/middleware"
 	"vector-ai/model"
+	"vector-ai/pgvector"
 	"vector-ai/postgres"
+	"vector-ai/provider"
 	"vector-ai/qdrant"
 	"vector-ai/route"
 
@@ -62,37 +65,77 @@ func main() {
 	}
 
 	var (
-		addr = flag.String("addr", server, "the address to connect to")
+		addr      = flag.String("addr", server, "the address to connect to")
+		reconcile = flag.String("reconcile", "", "reconcile postgres and qdrant for an org id, then exit")
+		repair    = flag.Bool("repair", false, "with -reconcile, delete orphan points and flag documents for reindex")
 	)
 
-	qdDriver, conn := dialQdrant(addr)
+	flag.Parse()
+
 	pgDriver := dialPostgres(connStr, runMode)
 	migratePostgres(connStr, runMode)
 
-	qdClient := qdrant.Qdr{
-		Driver:     qdDriver,
-		Connection: conn,
+	// VECTOR_STORE: qdrant (default), pgvector or memory
+	var vectorStore qdrant.Controls
+	switch os.Getenv("VECTOR_STORE") {
+	case "memory":
+		fmt.Println("using in-memory vector store")
+		vectorStore = qdrant.NewMemory()
+	case "pgvector":
+		fmt.Println("using pgvector store")
+		pgv := pgvector.Pgv{Driver: pgDriver}
+		if err := pgv.EnsureSchema(); err != nil {
+			log.Fatalf("could not create pgvector schema: %v", err)
+		}
+		vectorStore = pgv
+	default:
+		qdDriver, conn := dialQdrant(addr)
+		vectorStore = qdrant.Qdr{
+			Driver:     qdDriver,
+			Connection: conn,
+		}
 	}
 
 	pgClient := postgres.Pgx{
 		Driver: pgDriver,
 	}
 
+	// embedder for REST queries, sessions create their own
+	embedder, err := provider.NewEmbedder()
+	if err != nil {
+		panic(err)
+	}
+
 	// ws session tracker
 	jt := route.NewTracker()
 
 	handler := route.Handler{
-		QD: qdClient,
+		QD: vectorStore,
 		PG: pgClient,
 		TR: jt,
 		CL: clClient,
+		EM: embedder,
 	}
 
 	jt.SetHandler(handler)
 
-	err = license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
-	if err != nil {
-		panic(err)
+	if *reconcile != "" {
+		report, err := handler.Reconcile(*reconcile, *repair)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	// offline runs parse PDFs unlicensed
+	if key := os.Getenv(`UNIDOC_LICENSE_API_KEY`); key != "" || !provider.Offline() {
+		err = license.SetMeteredKey(key)
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	// start registration route
@@ -128,6 +171,15 @@ func main() {
 		adminRouter.Get("/admin/invite", handler.ListInvites)
 		adminRouter.Delete("/admin/invite/{inviteId}", handler.DeleteInvite)
 		adminRouter.Delete("/admin/invite/clear", handler.ClearExpiredInvites)
+		adminRouter.Put("/admin/qdrant/index", handler.IndexCollections)
+		adminRouter.Put("/admin/chunks/backfill", handler.BackfillChunks)
+		adminRouter.Get("/admin/org/{orgId}/reconcile", handler.ReconcileOrg)
+		adminRouter.Put("/admin/org/{orgId}/reconcile", handler.ReconcileOrg)
+		adminRouter.Post("/admin/org/{orgId}/snapshot", handler.CreateSnapshot)
+		adminRouter.Get("/admin/org/{orgId}/snapshot", handler.ListSnapshots)
+		adminRouter.Get("/admin/org/{orgId}/auto-snapshot", handler.ListAutoSnapshots)
+		adminRouter.Get("/admin/org/{orgId}/snapshot/{snapshotName}", handler.DownloadSnapshot)
+		adminRouter.Put("/admin/org/{orgId}/snapshot/{snapshotName}/restore", handler.RestoreSnapshot)
 
 		userRouter := router.Group()
 		userRouter.Middleware(middleware.Authentication, handler.Authorization) // handler.Permissions
@@ -147,12 +199,14 @@ func main() {
 		orgRouter.Put("/org/{orgId}", handler.UpdateOrg)
 		orgRouter.Delete("/org/{orgId}", handler.DeleteOrg)
 		orgRouter.Get("/org/{orgId}/users", handler.ListUsersByOrgId)
+		orgRouter.Post("/org/{orgId}/search", handler.SearchOrg).Validate(model.OrgSearchProps)
 		orgRouter.Get("/org/{orgId}/role", handler.ListOrgRoleAssignmentsByOrgId)
 		orgRouter.Get("/org/{orgId}/invite", handler.ListInvitesByOrgId)
 		orgRouter.Post("/org/{orgId}/invite", handler.CreateInvite)
 		orgRouter.Post("/org/{orgId}/subscription", handler.CreateOrgStripeSubscriptionAssociation)
 		orgRouter.Get("/org/{orgId}/subscription", handler.GetOrgStripeSubscriptionAssociation)
 		orgRouter.Delete("/org/{orgId}/subscription", handler.DeleteOrgStripeSubscriptionAssociation)
+		orgRouter.Get("/org/{orgId}/usage", handler.GetOrgUsage).Validate(model.UsageProps)
 
 		orgRouter.Get("/org/{orgId}/config", handler.ListOrgConfigs)
 		orgRouter.Get("/org/{orgId}/config/{configId}", handler.GetOrgConfig)
@@ -160,6 +214,7 @@ func main() {
 
 		orgRouter.Get("/org/{orgId}/user/{userId}/role", handler.ListOrgRoleAssignmentsByUserId)
 		orgRouter.Post("/org/{orgId}/user/{userId}/role", handler.CreateOrgRoleAssignment).Validate(model.OrgRoleAssignmentProps)
+		orgRouter.Get("/org/{orgId}/user/{userId}/usage", handler.GetUserUsage).Validate(model.UsageProps)
 		// orgRouter.Delete("/org/{orgId}/role/{roleId}", handler.DeleteOrgRoleAssignment)
 		// orgRouter.Put("/org/{orgId}/role/{roleId}/update", handler.UpdateOrgRoleAssignment)
 
@@ -171,6 +226,9 @@ func main() {
 		workRouter.Delete("/org/{orgId}/workspace/{workspaceId}", handler.DeleteWorkspace)
 		workRouter.Put("/org/{orgId}/workspace/{workspaceId}/clear", handler.ClearWorkspace)
 		workRouter.Put("/org/{orgId}/workspace/{workspaceId}/update", handler.UpdateWorkspace)
+		workRouter.Get("/org/{orgId}/workspace/{workspaceId}/export", handler.ExportWorkspace)
+		workRouter.Post("/org/{orgId}/workspace/{workspaceId}/search", handler.Search).Validate(model.SearchProps)
+		workRouter.Get("/org/{orgId}/workspace/{workspaceId}/usage", handler.GetWorkspaceUsage).Validate(model.UsageProps)
 
 		workRouter.Get("/org/{orgId}/workspace/{workspaceId}/config", handler.ListWorkspaceConfigs)
 		workRouter.Get("/org/{orgId}/workspace/{workspaceId}/config/{propertyName}", handler.GetWorkspaceConfig)
@@ -184,6 +242,7 @@ func main() {
 		convRouter.Delete("/org/{orgId}/workspace/{workspaceId}/conversation/{conversationId}", handler.DeleteConversation)
 		convRouter.Put("/org/{orgId}/workspace/{workspaceId}/conversation/{conversationId}/clear", handler.ClearConversation)
 		convRouter.Put("/org/{orgId}/workspace/{workspaceId}/conversation/{conversationId}/update", handler.UpdateConversation)
+		convRouter.Post("/org/{orgId}/workspace/{workspaceId}/conversation/{conversationId}/ask", handler.Ask).Validate(model.AskProps)
 
 		msgRouter := router.Group()
 		msgRouter.Middleware(middleware.Authentication, handler.Authorization)
@@ -194,8 +253,12 @@ func main() {
 		docRouter := router.Group()
 		docRouter.Middleware(middleware.Authentication, handler.Authorization)
 		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/document", handler.ListDocuments)
+		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/duplicates", handler.ListDuplicates)
+		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/topics", handler.ListTopics)
+		docRouter.Post("/org/{orgId}/workspace/{workspaceId}/topics", handler.ClusterWorkspaceTopics)
 		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/document/{documentId}", handler.GetDocument)
 		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/document/{documentId}/chunk", handler.ListChunks)
+		docRouter.Get("/org/{orgId}/workspace/{workspaceId}/document/{documentId}/similar", handler.ListSimilarDocuments)
 		docRouter.Delete("/org/{orgId}/workspace/{workspaceId}/document/{documentId}", handler.DeleteDocument)
 
 		ctxRouter := router.Group()
@@ -208,10 +271,13 @@ func main() {
 		tmplRouter := router.Group()
 		tmplRouter.Middleware(middleware.Authentication, handler.Authorization)
 		tmplRouter.Get("/org/{orgId}/workspace/{workspaceId}/template", handler.ListTemplates)
-		tmplRouter.Post("/org/{orgId}/workspace/{workspaceId}/template", handler.CreateTemplate)
+		tmplRouter.Post("/org/{orgId}/workspace/{workspaceId}/template", handler.CreateTemplate).Validate(model.TemplateProps)
 		tmplRouter.Get("/org/{orgId}/workspace/{workspaceId}/template/{templateId}", handler.GetTemplate)
-		tmplRouter.Put("/org/{orgId}/workspace/{workspaceId}/template/{templateId}", handler.UpdateTemplate)
+		tmplRouter.Put("/org/{orgId}/workspace/{workspaceId}/template/{templateId}", handler.UpdateTemplate).Validate(model.TemplateProps)
 		tmplRouter.Delete("/org/{orgId}/workspace/{workspaceId}/template/{templateId}", handler.DeleteTemplate)
+		tmplRouter.Get("/org/{orgId}/workspace/{workspaceId}/template/{templateId}/version", handler.ListTemplateVersions)
+		tmplRouter.Get("/org/{orgId}/workspace/{workspaceId}/template/{templateId}/version/{version}", handler.GetTemplateVersion)
+		tmplRouter.Post("/org/{orgId}/workspace/{workspaceId}/template/{templateId}/version/{version}/restore", handler.RestoreTemplateVersion)
 
 		syncRouter := router.Group()
 		syncRouter.Middleware(middleware.Authentication, handler.Authorization)
@@ -275,7 +341,6 @@ func dialPostgres(connStr string, runMode string) *pgxpool.Pool {
 
 func dialQdrant(addr *string) (pb.CollectionsClient, *grpc.ClientConn) {
 
-	flag.Parse()
 	// Set up a connection to the server.
 	config := &tls.Config{}
 	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(credentials.NewTLS(config)))
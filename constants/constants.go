@@ -3,5 +3,20 @@ package constants
 const (
 	LLM                          = "gpt-4o"
 	Embedder                     = "text-embedding-3-small"
+	VectorSize                   = 1536
+	Distance                     = "dot" // cosine, dot or euclid, for new orgs
+	OrgSearchDocumentLimit       = 40
+	OrgSearchChunkLimit          = 2
+	SimilarDocumentLimit         = 10
+	SimilarChunkPairs            = 3       // best matching chunk pairs per similar document
+	NearDuplicateCentroid        = 0.9     // document centroids closer than this are compared chunk by chunk
+	NearDuplicateChunk           = 0.97    // chunks closer than this count as the same
+	NearDuplicateOverlap         = 0.8     // share of matching chunks reported as a near duplicate
+	NearDuplicateDocuments       = 500     // most documents the duplicate report compares
+	NearDuplicateChunks          = 50      // most chunks per document it compares
+	NearDuplicatePairs           = 500     // most close document pairs it compares chunk by chunk
+	VssScoreThresholdMax         = 1000    // vssScoreThreshold is a similarity in thousandths
+	TopicLimit                   = 12      // most clusters per workspace
+	TopicSampleChunks            = 5       // chunks closest to a topic centroid shown to the LLM for its title
 	NonSubscriberFileUploadLimit = 5000000 // 5mb
 )
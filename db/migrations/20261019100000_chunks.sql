@@ -0,0 +1,26 @@
+-- +goose Up
+-- chunk text mirrored from qdrant for keyword (full-text) retrieval
+CREATE TABLE IF NOT EXISTS chunks (
+    point_id     UUID PRIMARY KEY,
+    workspace_id UUID NOT NULL,
+    document_id  UUID NOT NULL,
+    chunk_index  INTEGER NOT NULL,
+    text         TEXT NOT NULL,
+    tsv          TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED
+);
+
+CREATE INDEX IF NOT EXISTS chunks_tsv_idx ON chunks USING GIN (tsv);
+CREATE INDEX IF NOT EXISTS chunks_workspace_id_idx ON chunks (workspace_id);
+CREATE INDEX IF NOT EXISTS chunks_document_id_idx ON chunks (document_id);
+
+-- 0: dense, 1: keyword, 2: hybrid
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('c1d7e0a4-58b2-4f0e-9d3c-7a41e6b2f915', 'vssMode', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'c1d7e0a4-58b2-4f0e-9d3c-7a41e6b2f915', id, 'vssMode', 0 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property='vssMode';
+DELETE FROM configurations WHERE id='c1d7e0a4-58b2-4f0e-9d3c-7a41e6b2f915';
+DROP TABLE IF EXISTS chunks;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- minimum similarity score in thousandths, 0 disables the threshold
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('4b0f6d2e-9a13-4c7b-b8e5-0d3f92a6c471', 'vssScoreThreshold', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '4b0f6d2e-9a13-4c7b-b8e5-0d3f92a6c471', id, 'vssScoreThreshold', 0 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property='vssScoreThreshold';
+DELETE FROM configurations WHERE id='4b0f6d2e-9a13-4c7b-b8e5-0d3f92a6c471';
@@ -0,0 +1,19 @@
+-- +goose Up
+-- rerankMode 0: none, 1: llm, 2: cross-encoder
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config) VALUES
+('8e2a4c61-07d3-4b9f-a1c5-63f0d8b2e794', 'rerankMode', false, false, true),
+('d5f93b17-6c2e-48a0-9e71-2b4c8f0a6d35', 'rerankCandidates', false, false, true),
+('27c8e6a9-b450-4d1f-8f3b-9a6e1c5d0b82', 'rerankKeep', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '8e2a4c61-07d3-4b9f-a1c5-63f0d8b2e794', id, 'rerankMode', 0 FROM workspaces;
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'd5f93b17-6c2e-48a0-9e71-2b4c8f0a6d35', id, 'rerankCandidates', 40 FROM workspaces;
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '27c8e6a9-b450-4d1f-8f3b-9a6e1c5d0b82', id, 'rerankKeep', 10 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property IN ('rerankMode', 'rerankCandidates', 'rerankKeep');
+DELETE FROM configurations WHERE id IN ('8e2a4c61-07d3-4b9f-a1c5-63f0d8b2e794', 'd5f93b17-6c2e-48a0-9e71-2b4c8f0a6d35', '27c8e6a9-b450-4d1f-8f3b-9a6e1c5d0b82');
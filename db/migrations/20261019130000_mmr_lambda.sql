@@ -0,0 +1,11 @@
+-- +goose Up
+-- MMR lambda in hundredths, 0 disables diversification
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('f0b3d8c2-5e71-4a96-b2d4-8c1e7a9f3065', 'mmrLambda', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'f0b3d8c2-5e71-4a96-b2d4-8c1e7a9f3065', id, 'mmrLambda', 0 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property='mmrLambda';
+DELETE FROM configurations WHERE id='f0b3d8c2-5e71-4a96-b2d4-8c1e7a9f3065';
@@ -0,0 +1,11 @@
+-- +goose Up
+-- neighbouring chunks merged either side of each hit, 0 disables
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('a93e5f07-2d8b-4c61-b7f4-5e0c9d1a8b26', 'contextExpansion', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'a93e5f07-2d8b-4c61-b7f4-5e0c9d1a8b26', id, 'contextExpansion', 0 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property='contextExpansion';
+DELETE FROM configurations WHERE id='a93e5f07-2d8b-4c61-b7f4-5e0c9d1a8b26';
@@ -0,0 +1,18 @@
+-- +goose Up
+-- extra sub-queries generated for AI queries, 0 searches the single query
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('6d1c8a3f-e94b-4702-a5d8-b37f0c2e9164', 'multiQuery', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '6d1c8a3f-e94b-4702-a5d8-b37f0c2e9164', id, 'multiQuery', 0 FROM workspaces;
+
+-- 1 also searches with a hypothetical answer (HyDE)
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('e4a7b2d9-1f36-48c5-9b0e-72d5c8f1a3b0', 'hydeQuery', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'e4a7b2d9-1f36-48c5-9b0e-72d5c8f1a3b0', id, 'hydeQuery', 0 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property IN ('multiQuery', 'hydeQuery');
+DELETE FROM configurations WHERE id IN ('6d1c8a3f-e94b-4702-a5d8-b37f0c2e9164', 'e4a7b2d9-1f36-48c5-9b0e-72d5c8f1a3b0');
@@ -0,0 +1,6 @@
+-- +goose Up
+-- set by reconciliation when a document's vectors are missing or incomplete
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS reindex BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE documents DROP COLUMN IF EXISTS reindex;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- sha256 of a document's whitespace-normalized parsed text, for exact duplicate detection
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash TEXT;
+CREATE INDEX IF NOT EXISTS documents_content_hash_idx ON documents (workspace_id, content_hash);
+
+-- what an upload does when its content already exists in the workspace: 0 nothing, 1 warns, 2 skips
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('3f8b1e6c-a2d4-4c97-8e05-b6d1f4a9c723', 'duplicateMode', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '3f8b1e6c-a2d4-4c97-8e05-b6d1f4a9c723', id, 'duplicateMode', 1 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property='duplicateMode';
+DELETE FROM configurations WHERE id='3f8b1e6c-a2d4-4c97-8e05-b6d1f4a9c723';
+
+DROP INDEX IF EXISTS documents_content_hash_idx;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_hash;
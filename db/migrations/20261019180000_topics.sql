@@ -0,0 +1,23 @@
+-- +goose Up
+-- clusters of a workspace's chunks, replaced on every clustering run
+CREATE TABLE IF NOT EXISTS topics (
+    id           UUID PRIMARY KEY,
+    workspace_id UUID NOT NULL,
+    title        TEXT NOT NULL,
+    chunks       INTEGER NOT NULL,
+    timestamp    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS topics_workspace_id_idx ON topics (workspace_id);
+
+-- documents with chunks in a topic
+CREATE TABLE IF NOT EXISTS topic_documents (
+    topic_id    UUID NOT NULL REFERENCES topics (id) ON DELETE CASCADE,
+    document_id UUID NOT NULL,
+    chunks      INTEGER NOT NULL,
+    PRIMARY KEY (topic_id, document_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS topic_documents;
+DROP TABLE IF EXISTS topics;
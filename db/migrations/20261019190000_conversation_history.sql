@@ -0,0 +1,18 @@
+-- +goose Up
+-- earlier conversation turns given to AI queries, 0 disables history
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('1b7e9c4d-3a52-4f86-b0d1-e8a6c2f5947b', 'historyTurns', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '1b7e9c4d-3a52-4f86-b0d1-e8a6c2f5947b', id, 'historyTurns', 5 FROM workspaces;
+
+-- token budget for those turns, oldest turns are dropped first. 0 is unlimited
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('5c2d8f1a-7e94-4b03-a6c5-d9f1b3e8027a', 'historyTokens', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '5c2d8f1a-7e94-4b03-a6c5-d9f1b3e8027a', id, 'historyTokens', 2000 FROM workspaces;
+
+-- +goose Down
+DELETE FROM workspace_config WHERE property IN ('historyTurns', 'historyTokens');
+DELETE FROM configurations WHERE id IN ('1b7e9c4d-3a52-4f86-b0d1-e8a6c2f5947b', '5c2d8f1a-7e94-4b03-a6c5-d9f1b3e8027a');
@@ -0,0 +1,29 @@
+-- +goose Up
+-- chat model provider: 0 openai, 1 anthropic, 2 openai-compatible endpoint, 3 fake
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('b2e4d7a1-6f83-4c59-9a0e-3d1c8b5f7e26', 'llmProvider', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'b2e4d7a1-6f83-4c59-9a0e-3d1c8b5f7e26', id, 'llmProvider', 0 FROM workspaces;
+
+-- index into the provider's model list
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('e7a3c9f5-0d21-4b86-b4f7-6a8e2c1d9b53', 'llmModel', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), 'e7a3c9f5-0d21-4b86-b4f7-6a8e2c1d9b53', id, 'llmModel', 0 FROM workspaces;
+
+-- hundredths, 0 keeps the provider default
+INSERT INTO configurations (id, property, org_config, user_config, workspace_config)
+VALUES ('4d9f1b6e-c837-4a20-8e5b-f2a7d0c3e619', 'llmTemperature', false, false, true);
+
+INSERT INTO workspace_config (id, configuration_id, workspace_id, property, value)
+SELECT gen_random_uuid(), '4d9f1b6e-c837-4a20-8e5b-f2a7d0c3e619', id, 'llmTemperature', 0 FROM workspaces;
+
+-- model that wrote each AI message
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS model TEXT;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN IF EXISTS model;
+DELETE FROM workspace_config WHERE property IN ('llmProvider', 'llmModel', 'llmTemperature');
+DELETE FROM configurations WHERE id IN ('b2e4d7a1-6f83-4c59-9a0e-3d1c8b5f7e26', 'e7a3c9f5-0d21-4b86-b4f7-6a8e2c1d9b53', '4d9f1b6e-c837-4a20-8e5b-f2a7d0c3e619');
@@ -0,0 +1,8 @@
+-- +goose Up
+-- YAML replies to schema'd analyses, parsed to JSON, and whether they matched the schema
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS structured JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS structured_valid BOOLEAN;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN IF EXISTS structured_valid;
+ALTER TABLE messages DROP COLUMN IF EXISTS structured;
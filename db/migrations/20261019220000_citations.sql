@@ -0,0 +1,11 @@
+-- +goose Up
+-- context rows are now only the chunks an AI message cited: the order of the citation
+-- in the message and the span of message text it supports
+ALTER TABLE context ADD COLUMN IF NOT EXISTS position INTEGER;
+ALTER TABLE context ADD COLUMN IF NOT EXISTS span_start INTEGER;
+ALTER TABLE context ADD COLUMN IF NOT EXISTS span_end INTEGER;
+
+-- +goose Down
+ALTER TABLE context DROP COLUMN IF EXISTS span_end;
+ALTER TABLE context DROP COLUMN IF EXISTS span_start;
+ALTER TABLE context DROP COLUMN IF EXISTS position;
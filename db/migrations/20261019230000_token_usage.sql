@@ -0,0 +1,37 @@
+-- +goose Up
+-- LLM and embedding tokens spent per call, with what they cost when recorded
+CREATE TABLE IF NOT EXISTS token_usage (
+    id                UUID PRIMARY KEY,
+    org_id            UUID NOT NULL,
+    workspace_id      UUID NOT NULL,
+    conversation_id   UUID,
+    message_id        UUID,
+    document_id       UUID,
+    user_id           TEXT,
+    operation         TEXT NOT NULL,
+    model             TEXT NOT NULL,
+    prompt_tokens     BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    embedding_tokens  BIGINT NOT NULL DEFAULT 0,
+    cost_micros       BIGINT NOT NULL DEFAULT 0,
+    timestamp         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS token_usage_org_id_timestamp_idx ON token_usage (org_id, timestamp);
+CREATE INDEX IF NOT EXISTS token_usage_workspace_id_timestamp_idx ON token_usage (workspace_id, timestamp);
+CREATE INDEX IF NOT EXISTS token_usage_user_id_timestamp_idx ON token_usage (user_id, timestamp);
+
+-- token totals reported alongside storage on each usage event
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS calls BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS prompt_tokens BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS completion_tokens BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS embedding_tokens BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS cost_micros BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE usage_events DROP COLUMN IF EXISTS cost_micros;
+ALTER TABLE usage_events DROP COLUMN IF EXISTS embedding_tokens;
+ALTER TABLE usage_events DROP COLUMN IF EXISTS completion_tokens;
+ALTER TABLE usage_events DROP COLUMN IF EXISTS prompt_tokens;
+ALTER TABLE usage_events DROP COLUMN IF EXISTS calls;
+DROP TABLE IF EXISTS token_usage;
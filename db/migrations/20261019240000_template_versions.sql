@@ -0,0 +1,30 @@
+-- +goose Up
+-- declared variables of a template and its current version
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS variables JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- every version a template has had, the current one included
+CREATE TABLE IF NOT EXISTS template_versions (
+    template_id UUID NOT NULL,
+    version     INTEGER NOT NULL,
+    name        TEXT NOT NULL,
+    text        TEXT NOT NULL,
+    variables   JSONB NOT NULL DEFAULT '[]',
+    user_id     TEXT NOT NULL,
+    author_name TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (template_id, version)
+);
+
+INSERT INTO template_versions (template_id, version, name, text, variables, user_id, author_name, created_at)
+SELECT id, version, name, text, variables, user_id, author_name, created_at FROM templates
+ON CONFLICT DO NOTHING;
+
+-- the template version an AI message ran with
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS template_version INTEGER;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN IF EXISTS template_version;
+DROP TABLE IF EXISTS template_versions;
+ALTER TABLE templates DROP COLUMN IF EXISTS version;
+ALTER TABLE templates DROP COLUMN IF EXISTS variables;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- usage events are written before their tokens reach Stripe, and marked once they do
+ALTER TABLE usage_events ADD COLUMN IF NOT EXISTS tokens_reported BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+ALTER TABLE usage_events DROP COLUMN IF EXISTS tokens_reported;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- new Drive files a sync skipped as duplicates, checked again once they change
+CREATE TABLE IF NOT EXISTS drive_skipped_document (
+    workspace_id  UUID NOT NULL,
+    drive_id      TEXT NOT NULL,
+    last_modified TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, drive_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS drive_skipped_document;
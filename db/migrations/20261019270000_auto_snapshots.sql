@@ -0,0 +1,14 @@
+-- +goose Up
+-- snapshots taken before destructive operations, kept after the org itself is deleted
+CREATE TABLE IF NOT EXISTS auto_snapshots (
+    id            UUID PRIMARY KEY,
+    org_id        TEXT NOT NULL,
+    snapshot_name TEXT NOT NULL,
+    operation     TEXT NOT NULL,
+    timestamp     TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS auto_snapshots_org_id_idx ON auto_snapshots (org_id, timestamp);
+
+-- +goose Down
+DROP TABLE IF EXISTS auto_snapshots;
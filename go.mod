@@ -23,6 +23,7 @@ require (
 	golang.org/x/oauth2 v0.18.0
 	google.golang.org/api v0.172.0
 	google.golang.org/grpc v1.62.1
+	gopkg.in/yaml.v3 v3.0.1
 	goyave.dev/goyave/v4 v4.4.11
 )
 
@@ -94,6 +95,5 @@ require (
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240325203815-454cdb8f5daa // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gorm.io/gorm v1.25.1 // indirect
 )
@@ -1,8 +1,11 @@
 package model
 
+import "time"
+
 type ContextHolder struct {
 	Results []ContextLoaderScored `json:"results"`
 	Query   string                `json:"query"`
+	Queries []string              `json:"queries,omitempty"` // every query searched, when expanded
 }
 
 func (cv *ContextHolder) AddLoader(val ContextLoaderScored) []ContextLoaderScored {
@@ -26,7 +29,61 @@ func (cv *ChunkValues) AddChunk(val ScoredChunk) []ScoredChunk {
 }
 
 type ScoredChunk struct {
-	ID    string  `json:"id"`
-	Value string  `json:"value"`
-	Score float32 `json:"score"`
+	ID          string  `json:"id"`
+	Value       string  `json:"value"`
+	Score       float32 `json:"score"`                 // retrieval score
+	RerankScore float32 `json:"rerankScore,omitempty"` // second stage score
+}
+
+// Citation is a marker in an AI reply that names a supplied chunk. Start and End
+// bound the reply text it supports, as byte offsets into the UTF-8 text.
+type Citation struct {
+	PointID    string
+	DocumentID string
+	Position   int32
+	Start      int32
+	End        int32
+}
+
+// OrgContextHolder groups org-wide search results by workspace, then document
+type OrgContextHolder struct {
+	Results []WorkspaceContext `json:"results"`
+	Query   string             `json:"query"`
+}
+
+type WorkspaceContext struct {
+	WorkspaceID   string                `json:"workspaceId"`
+	WorkspaceName string                `json:"workspaceName"`
+	Results       []ContextLoaderScored `json:"results"`
+}
+
+// Hit is a single retrieved chunk, independent of which index produced it
+type Hit struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspaceId,omitempty"`
+	DocumentID  string    `json:"documentId"`
+	Index       int64     `json:"index"`
+	Value       string    `json:"value"`
+	Score       float32   `json:"score"`
+	RerankScore float32   `json:"rerankScore,omitempty"`
+	Vector      []float32 `json:"-"` // only populated for MMR
+}
+
+// Chunk is one stored point of a document, independent of the vector store
+type Chunk struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspaceId"`
+	DocumentID  string    `json:"documentId"`
+	Index       int64     `json:"index"`
+	Text        string    `json:"chunk"`
+	Hash        string    `json:"hash,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Vector      []float32 `json:"vector,omitempty"`
+	Uploaded    int64     `json:"uploaded,omitempty"` // unix seconds, 0 for points stored before it was recorded
+}
+
+// DocumentPoints counts a document's points and when the latest was uploaded
+type DocumentPoints struct {
+	Points   int64
+	Uploaded time.Time
 }
@@ -65,6 +65,7 @@ type VectorStorageProfile struct {
 	OrgID       string
 	WorkspaceID string
 	DocumentID  string
+	UserID      string // who triggered the upload, for usage accounting
 }
 
 type NewDriveProfile struct {
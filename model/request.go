@@ -29,6 +29,39 @@ var (
 		"value": validation.List{"required", "integer"},
 	}
 
+	SearchProps = validation.RuleSet{
+		"query":       validation.List{"required", "string"},
+		"documentIds": validation.List{"array:string"},
+		"tagIds":      validation.List{"array:string"},
+	}
+
+	AskProps = validation.RuleSet{
+		"queryText":      validation.List{"required", "string"},
+		"templateId":     validation.List{"required", "string"},
+		"responseSchema": validation.List{"string"},
+		"forceContext":   validation.List{"string"},
+		"authorName":     validation.List{"string"},
+		"documentIds":    validation.List{"array:string"},
+		"tagIds":         validation.List{"array:string"},
+		"variables":      validation.List{"object"},
+	}
+
+	TemplateProps = validation.RuleSet{
+		"name":      validation.List{"required", "string"},
+		"text":      validation.List{"required", "string"},
+		"variables": validation.List{"array"},
+	}
+
+	OrgSearchProps = validation.RuleSet{
+		"query": validation.List{"required", "string"},
+	}
+
+	UsageProps = validation.RuleSet{
+		"from":     validation.List{"date"},
+		"to":       validation.List{"date"},
+		"interval": validation.List{"string", "in:hour,day,week,month"},
+	}
+
 	OrgRoleAssignmentProps = validation.RuleSet{
 		"userIds": validation.List{"required", "string"},
 	}
@@ -59,8 +92,12 @@ type WebSocketsMessage struct {
 	VssText        string         `db:"vss_text" json:"vssText"`
 	ForceContext   string         `db:"force_context" json:"forceContext"`
 	ResponseSchema string         `db:"response_schema" json:"responseSchema"`
+	Variables      map[string]any `json:"variables"`   // values of the template's variables
+	DocumentIDs    []string       `json:"documentIds"` // optional vss scope
+	TagIDs         []string       `json:"tagIds"`      // optional vss scope
 	AuthorType     string         `db:"author_type" json:"authorType"`
 	AuthorName     string         `db:"author_name" json:"authorName"`
+	UserID         string         `json:"-"` // set from the token, for usage accounting
 	Timestamp      time.Time      `db:"timestamp" json:"timestamp"`
 }
 
@@ -68,10 +105,16 @@ type UserWebsocketEnvelope struct {
 	Message      *WebSocketsMessage `json:"message"`
 	DriveFolders *DriveFolders      `json:"driveFolders"`
 	SyncFolders  *SyncFolders       `json:"syncFolders"`
+	OrgSearch    *OrgSearch         `json:"orgSearch"`
 	Token        *string            `json:"token"`
 	// WebsocketFile *WebsocketFile     `json:"file"`
 }
 
+// OrgSearch queries every workspace in the org the user can access
+type OrgSearch struct {
+	Query string `json:"query"`
+}
+
 type DriveFolders struct {
 	Folders []string `json:"folders"`
 }
@@ -89,4 +132,70 @@ type FileHeader struct {
 type VssOptions struct {
 	VssDocumentLimit uint32 `json:"vssDocumentLimit"`
 	VssChunkLimit    uint32 `json:"vssChunkLimit"`
+	VssMode          uint32 `json:"vssMode"`
+
+	// thousandths, 0 disables. a maximum distance on euclid collections
+	VssScoreThreshold uint32 `json:"vssScoreThreshold"`
+
+	RerankMode       uint32 `json:"rerankMode"`
+	RerankCandidates uint32 `json:"rerankCandidates"` // chunks fetched for reranking
+	RerankKeep       uint32 `json:"rerankKeep"`       // chunks kept after reranking
+
+	// hundredths, 0 disables MMR diversification
+	MmrLambda uint32 `json:"mmrLambda"`
+
+	// neighbouring chunks merged either side of each hit for AI queries
+	ContextExpansion uint32 `json:"contextExpansion"`
+
+	// extra sub-queries generated for AI queries, 0 searches the single query
+	MultiQuery uint32 `json:"multiQuery"`
+
+	// 1 also searches with a hypothetical answer (HyDE)
+	HydeQuery uint32 `json:"hydeQuery"`
+
+	// what uploads do when their content already exists in the workspace
+	DuplicateMode uint32 `json:"duplicateMode"`
+
+	// earlier conversation turns given to AI queries, 0 disables history
+	HistoryTurns uint32 `json:"historyTurns"`
+
+	// token budget for those turns, oldest turns are dropped first. 0 is unlimited
+	HistoryTokens uint32 `json:"historyTokens"`
+
+	// chat model answering AI queries, see provider.Models
+	LlmProvider uint32 `json:"llmProvider"`
+	LlmModel    uint32 `json:"llmModel"`
+
+	// hundredths, 0 keeps the provider default
+	LlmTemperature uint32 `json:"llmTemperature"`
+}
+
+// workspace vssMode values
+const (
+	VssModeDense   = 0
+	VssModeKeyword = 1
+	VssModeHybrid  = 2
+)
+
+// workspace duplicateMode values
+const (
+	DuplicateModeOff  = 0
+	DuplicateModeWarn = 1
+	DuplicateModeSkip = 2
+)
+
+// UsageFilter narrows token usage to an org's calls in [From, To), optionally by
+// workspace or user
+type UsageFilter struct {
+	OrgID       string
+	WorkspaceID string
+	UserID      string
+	From        time.Time
+	To          time.Time
+}
+
+// VssFilter narrows a search to documents matching any of the ids or tags
+type VssFilter struct {
+	DocumentIDs []string `json:"documentIds"`
+	TagIDs      []string `json:"tagIds"`
 }
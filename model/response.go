@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type HTTPResponse struct {
 	Message string `json:"message"`
 }
@@ -11,6 +13,44 @@ type ConsumableContext struct {
 	Text        string `json:"text"`
 }
 
+// AnswerSpan is a cited part of an AI message, Start and End being byte offsets into
+// its text, and the chunk it cites
+type AnswerSpan struct {
+	Position     int32  `json:"position"`
+	Start        int32  `json:"start"`
+	End          int32  `json:"end"`
+	Text         string `json:"text"`
+	PointID      string `json:"pointId"`
+	DocumentID   string `json:"documentId"`
+	DocumentName string `json:"documentName"`
+	ChunkText    string `json:"chunkText"`
+}
+
+// UsageTotals sums token usage rows
+type UsageTotals struct {
+	Calls            int64 `json:"calls"`
+	PromptTokens     int64 `json:"promptTokens"`
+	CompletionTokens int64 `json:"completionTokens"`
+	EmbeddingTokens  int64 `json:"embeddingTokens"`
+	CostMicros       int64 `json:"costMicros"`
+}
+
+// UsageBucket is the usage of one model and operation in one interval
+type UsageBucket struct {
+	Start     time.Time `json:"start"`
+	Model     string    `json:"model"`
+	Operation string    `json:"operation"`
+	UsageTotals
+}
+
+type UsageReport struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval string        `json:"interval"`
+	Totals   UsageTotals   `json:"totals"`
+	Buckets  []UsageBucket `json:"buckets"`
+}
+
 type AdminResponse struct {
 	IsAdmin bool `json:"isAdmin"`
 }
@@ -41,3 +81,108 @@ type WorkspaceResponse struct {
 	NumberOfDocuments int64   `json:"numberOfDocuments"`
 	DocumentsSize     int64   `json:"documentsSize"`
 }
+
+type Snapshot struct {
+	Name         string    `json:"name"`
+	CreationTime time.Time `json:"creationTime"`
+	Size         int64     `json:"size"`
+}
+
+// AutoSnapshot records a snapshot taken before a destructive operation
+type AutoSnapshot struct {
+	ID           string    `json:"id"`
+	OrgID        string    `json:"orgId"`
+	SnapshotName string    `json:"snapshotName"`
+	Operation    string    `json:"operation"` // deleteOrg, deleteWorkspace, clearWorkspace or reconcile
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// ReconciliationReport compares an org's qdrant points with its documents table
+type ReconciliationReport struct {
+	OrgID             string           `json:"orgId"`
+	Points            int64            `json:"points"`
+	Documents         int              `json:"documents"`
+	OrphanPoints      []OrphanPoints   `json:"orphanPoints"`      // points without a document
+	MissingVectors    []VectorMismatch `json:"missingVectors"`    // documents without points
+	MismatchedVectors []VectorMismatch `json:"mismatchedVectors"` // documents whose vectors count is off
+	Repaired          bool             `json:"repaired"`
+	Snapshot          string           `json:"snapshot,omitempty"` // taken before the repair deleted orphans
+}
+
+type OrphanPoints struct {
+	WorkspaceID string `json:"workspaceId"`
+	DocumentID  string `json:"documentId"`
+	Points      int64  `json:"points"`
+}
+
+type VectorMismatch struct {
+	WorkspaceID string `json:"workspaceId"`
+	DocumentID  string `json:"documentId"`
+	Name        string `json:"name"`
+	Expected    int64  `json:"expected"` // documents.vectors
+	Actual      int64  `json:"actual"`   // points in qdrant
+}
+
+// CollectionInfo describes an org's vector collection
+type CollectionInfo struct {
+	Status      string `json:"status"`
+	PointsCount uint64 `json:"pointsCount"`
+	VectorSize  uint64 `json:"vectorSize"`
+	Distance    string `json:"distance"`
+}
+
+// SimilarDocument is a document close to another one, with the chunk pairs that match best
+type SimilarDocument struct {
+	DocumentID string      `json:"documentId"`
+	Name       string      `json:"name"`
+	Score      float32     `json:"score"`
+	Pairs      []ChunkPair `json:"pairs"`
+}
+
+type ChunkPair struct {
+	Source ConsumableContext `json:"source"` // chunk of the requested document
+	Match  ConsumableContext `json:"match"`
+	Score  float32           `json:"score"`
+}
+
+// TopicJob is the latest clustering run of a workspace
+type TopicJob struct {
+	Status   string     `json:"status"` // running, done, failed
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Topics   int        `json:"topics"` // clustered by a done run
+	Error    string     `json:"error,omitempty"`
+}
+
+// Topic job statuses
+const (
+	TopicJobRunning = "running"
+	TopicJobDone    = "done"
+	TopicJobFailed  = "failed"
+)
+
+// Topics are a workspace's stored topics and its latest clustering run, if the
+// server has run one since it started
+type Topics struct {
+	Topics []Topic   `json:"topics"`
+	Job    *TopicJob `json:"job,omitempty"`
+}
+
+// DuplicateReport lists documents of a workspace with the same content
+type DuplicateReport struct {
+	WorkspaceID string           `json:"workspaceId"`
+	Exact       []DuplicateGroup `json:"exact"`     // identical parsed text
+	Near        []NearDuplicate  `json:"near"`      // mostly overlapping chunk vectors
+	Unhashed    []Document       `json:"unhashed"`  // without a content hash, not checked for exact duplicates
+	Truncated   bool             `json:"truncated"` // near duplicates were looked for among some documents or chunks only
+}
+
+type DuplicateGroup struct {
+	ContentHash string     `json:"contentHash"`
+	Documents   []Document `json:"documents"`
+}
+
+type NearDuplicate struct {
+	Documents []Document `json:"documents"`
+	Overlap   float32    `json:"overlap"` // share of the smaller document's chunks found in the other
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -97,7 +98,16 @@ type Message struct {
 	Text           string         `db:"text" json:"text"`
 	AuthorType     string         `db:"author_type" json:"authorType"`
 	AuthorName     string         `db:"author_name" json:"authorName"`
-	Timestamp      time.Time      `db:"timestamp" json:"timestamp"`
+	Model          string         `db:"model" json:"model,omitempty"` // model behind an AI message
+
+	TemplateVersion *int32 `db:"template_version" json:"templateVersion,omitempty"` // version an AI message ran with
+
+	// the YAML of a schema'd AI message as JSON, and whether it matched the schema
+	Structured      json.RawMessage `db:"structured" json:"structured,omitempty"`
+	StructuredValid *bool           `db:"structured_valid" json:"structuredValid,omitempty"`
+	Timestamp       time.Time       `db:"timestamp" json:"timestamp"`
+
+	Spans []AnswerSpan `db:"-" json:"spans,omitempty"` // cited parts of an AI message
 }
 
 type Document struct {
@@ -109,6 +119,7 @@ type Document struct {
 	Vectors     int64     `db:"vectors" json:"vectors,omitempty"`
 	ChunkSize   int64     `db:"chunk_size" json:"chunkSize,omitempty"`
 	Timestamp   time.Time `db:"timestamp" json:"timestamp,omitempty"`
+	Reindex     bool      `db:"reindex" json:"reindex,omitempty"`
 }
 
 type DriveDocumentSync struct {
@@ -128,14 +139,45 @@ type DriveFolderSync struct {
 }
 
 type Template struct {
-	ID          string    `db:"id" json:"id"`
-	OrgID       string    `db:"org_id" json:"orgId"`
-	WorkspaceID string    `db:"workspace_id" json:"workspaceId"`
-	Name        string    `db:"name" json:"name"`
-	Text        string    `db:"text" json:"text"`
-	UserID      string    `db:"user_id" json:"userId"`
-	AuthorName  string    `db:"author_name" json:"authorName"`
-	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+	ID          string             `db:"id" json:"id"`
+	OrgID       string             `db:"org_id" json:"orgId"`
+	WorkspaceID string             `db:"workspace_id" json:"workspaceId"`
+	Name        string             `db:"name" json:"name"`
+	Text        string             `db:"text" json:"text"`
+	Variables   []TemplateVariable `db:"variables" json:"variables"`
+	Version     int32              `db:"version" json:"version"`
+	UserID      string             `db:"user_id" json:"userId"`
+	AuthorName  string             `db:"author_name" json:"authorName"`
+	CreatedAt   time.Time          `db:"created_at" json:"createdAt"`
+}
+
+// template variable types
+const (
+	VariableString  = "string"
+	VariableNumber  = "number"
+	VariableBoolean = "boolean"
+	VariableDate    = "date" // 2006-01-02
+)
+
+// TemplateVariable is declared by a template, whose text uses it as {{.name}}, and
+// filled in by each query
+type TemplateVariable struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Default  any    `json:"default,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// TemplateVersion is a template as it was after a create, update or restore
+type TemplateVersion struct {
+	TemplateID string             `db:"template_id" json:"templateId"`
+	Version    int32              `db:"version" json:"version"`
+	Name       string             `db:"name" json:"name"`
+	Text       string             `db:"text" json:"text"`
+	Variables  []TemplateVariable `db:"variables" json:"variables"`
+	UserID     string             `db:"user_id" json:"userId"`
+	AuthorName string             `db:"author_name" json:"authorName"`
+	CreatedAt  time.Time          `db:"created_at" json:"createdAt"`
 }
 
 type Context struct {
@@ -143,6 +185,11 @@ type Context struct {
 	DocumentID string `db:"document_id" json:"documentId"`
 	MessageID  string `db:"message_id" json:"messageId"`
 	PointID    string `db:"point_id" json:"pointId"`
+
+	// where the message cited the point; nil for rows saved before citations
+	Position  *int32 `db:"position" json:"position,omitempty"`
+	SpanStart int32  `db:"span_start" json:"spanStart"`
+	SpanEnd   int32  `db:"span_end" json:"spanEnd"`
 }
 
 type User struct {
@@ -156,9 +203,44 @@ type UsageEvent struct {
 	ID                  string    `db:"id" json:"id"`
 	OrgID               string    `db:"org_id" json:"orgId"`
 	TotalFileSizeAmount int64     `db:"total_file_size_amount" json:"totalFileSizeAmount"`
+	UsageTotals                   // token usage since the previous event
+	TokensReported      bool      `db:"tokens_reported" json:"tokensReported"`
 	Timestamp           time.Time `db:"timestamp" json:"timestamp"`
 }
 
+// token_usage operations
+const (
+	UsageAnalysis  = "analysis"  // the AI reply to a message, with its repairs
+	UsageVss       = "vss"       // constructing and expanding the search, and embedding its queries
+	UsageEmbedding = "embedding" // splitEmbedUpload
+)
+
+// TokenUsage is the LLM and embedding spend of one call. Ids that don't apply are empty.
+type TokenUsage struct {
+	ID               string    `db:"id" json:"id"`
+	OrgID            string    `db:"org_id" json:"orgId"`
+	WorkspaceID      string    `db:"workspace_id" json:"workspaceId"`
+	ConversationID   string    `db:"conversation_id" json:"conversationId,omitempty"`
+	MessageID        string    `db:"message_id" json:"messageId,omitempty"`
+	DocumentID       string    `db:"document_id" json:"documentId,omitempty"`
+	UserID           string    `db:"user_id" json:"userId,omitempty"`
+	Operation        string    `db:"operation" json:"operation"`
+	Model            string    `db:"model" json:"model"`
+	PromptTokens     int64     `db:"prompt_tokens" json:"promptTokens"`
+	CompletionTokens int64     `db:"completion_tokens" json:"completionTokens"`
+	EmbeddingTokens  int64     `db:"embedding_tokens" json:"embeddingTokens"`
+	CostMicros       int64     `db:"cost_micros" json:"costMicros"` // millionths of a USD
+	Timestamp        time.Time `db:"timestamp" json:"timestamp"`
+}
+
+// Add sums the tokens of two calls to the same model
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.EmbeddingTokens += other.EmbeddingTokens
+	return u
+}
+
 type Tag struct {
 	ID    string `db:"id" json:"id"`
 	OrgID string `db:"org_id" json:"orgId"`
@@ -177,3 +259,19 @@ type TagParentAssociation struct {
 	ParentTagID string `db:"parent_tag_id" json:"parentTagId"`
 	ChildTagID  string `db:"child_tag_id" json:"childTagId"`
 }
+
+// Topic is a cluster of a workspace's chunks, titled by the LLM
+type Topic struct {
+	ID          string          `db:"id" json:"id"`
+	WorkspaceID string          `db:"workspace_id" json:"workspaceId"`
+	Title       string          `db:"title" json:"title"`
+	Chunks      int64           `db:"chunks" json:"chunks"`
+	Timestamp   time.Time       `db:"timestamp" json:"timestamp"`
+	Documents   []TopicDocument `json:"documents"`
+}
+
+type TopicDocument struct {
+	DocumentID string `db:"document_id" json:"documentId"`
+	Name       string `json:"name"`
+	Chunks     int64  `db:"chunks" json:"chunks"` // chunks of the document in the topic
+}
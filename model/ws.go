@@ -68,6 +68,14 @@ func QueryStatus(text string, workspaceId string, conversationId string) Envelop
 	return Envelope{Data: update, UpdateType: "QueryStatus", WorkspaceID: workspaceId, ConversationID: conversationId}
 }
 
+// QueryError tells the conversation its query failed
+func QueryError(errorMessage string, workspaceId string, conversationId string) Envelope {
+	update, err := json.Marshal(HTTPResponse{Message: errorMessage})
+	check(err)
+
+	return Envelope{Data: update, UpdateType: "QueryError", WorkspaceID: workspaceId, ConversationID: conversationId}
+}
+
 func VssResponse(context ContextHolder, workspaceId string, conversationId string) Envelope {
 	vssJson, err := json.Marshal(context)
 	check(err)
@@ -75,6 +83,13 @@ func VssResponse(context ContextHolder, workspaceId string, conversationId strin
 	return Envelope{Data: vssJson, UpdateType: "VssResponse", WorkspaceID: workspaceId, ConversationID: conversationId}
 }
 
+func OrgSearchResponse(context OrgContextHolder, workspaceId string, conversationId string) Envelope {
+	searchJson, err := json.Marshal(context)
+	check(err)
+
+	return Envelope{Data: searchJson, UpdateType: "OrgSearchResponse", WorkspaceID: workspaceId, ConversationID: conversationId}
+}
+
 // upload event
 func UploadStatus(event UploadEvent, workspaceId string, documentId string, progress int) Envelope {
 	update, err := json.Marshal(ProgressUpdate{DocumentID: documentId, Event: event, Progress: progress})
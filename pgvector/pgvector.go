@@ -0,0 +1,295 @@
+package pgvector
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"vector-ai/model"
+	"vector-ai/qdrant"
+
+	pg "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pgv is a vector store on Postgres with the pgvector extension. It implements
+// qdrant.Controls so it can replace qdrant for self-hosted deployments, sharing the
+// app's pgxpool. Collections are rows keyed by orgId rather than separate tables.
+type Pgv struct {
+	Driver *pgxpool.Pool
+}
+
+var errSnapshotsUnsupported = fmt.Errorf("%w, back up postgres instead", qdrant.ErrSnapshotsUnsupported)
+
+// hnsw indexes vectors of up to 2000 dimensions, larger collections are scanned
+const maxIndexedDimensions = 2000
+
+// EnsureSchema creates the extension and tables if missing. It runs at startup when
+// pgvector is selected, since migrations only run in dev and most databases
+// backing qdrant deployments won't have the extension.
+func (pgv Pgv) EnsureSchema() error {
+	_, err := pgv.Driver.Exec(context.Background(), `
+	CREATE EXTENSION IF NOT EXISTS vector;
+
+	CREATE TABLE IF NOT EXISTS vector_collections (
+		name        TEXT PRIMARY KEY,
+		vector_size INTEGER NOT NULL,
+		distance    TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS vector_points (
+		id           UUID PRIMARY KEY,
+		collection   TEXT NOT NULL REFERENCES vector_collections (name) ON DELETE CASCADE,
+		workspace_id TEXT NOT NULL,
+		document_id  TEXT NOT NULL,
+		chunk_index  INTEGER NOT NULL,
+		chunk        TEXT NOT NULL,
+		hash         TEXT NOT NULL,
+		tags         TEXT[] NOT NULL DEFAULT '{}',
+		embedding    VECTOR NOT NULL
+	);
+
+	-- unix seconds of the upload, 0 for points stored before it was recorded
+	ALTER TABLE vector_points ADD COLUMN IF NOT EXISTS uploaded BIGINT NOT NULL DEFAULT 0;
+
+	CREATE INDEX IF NOT EXISTS vector_points_workspace_idx ON vector_points (collection, workspace_id);
+	CREATE INDEX IF NOT EXISTS vector_points_document_idx ON vector_points (collection, document_id, chunk_index);
+	CREATE INDEX IF NOT EXISTS vector_points_tags_idx ON vector_points USING GIN (tags);`)
+	if err != nil {
+		return err
+	}
+
+	// collections created before their index was
+	rows, err := pgv.Driver.Query(context.Background(), `SELECT name, vector_size, distance FROM vector_collections`)
+	if err != nil {
+		return err
+	}
+	collections, err := pg.CollectRows(rows, pg.RowToStructByPos[collection])
+	if err != nil {
+		return err
+	}
+
+	for _, c := range collections {
+		if err := pgv.createIndex(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// a row of vector_collections
+type collection struct {
+	Name       string
+	VectorSize int
+	Distance   string
+}
+
+// embedding has no dimension since collections differ in vector size, so each
+// collection gets a partial hnsw index over embedding cast to its own
+func (pgv Pgv) createIndex(c collection) error {
+	if c.VectorSize > maxIndexedDimensions {
+		fmt.Println("Collection", c.Name, "has", c.VectorSize, "dimensions, too many to index")
+		return nil
+	}
+
+	_, err := pgv.Driver.Exec(context.Background(), fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %s ON vector_points USING hnsw ((embedding::vector(%d)) %s) WHERE collection = %s`,
+		indexName(c.Name), c.VectorSize, metrics[c.Distance].opclass, quoteLiteral(c.Name)))
+
+	return err
+}
+
+func indexName(orgId string) string {
+	return pg.Identifier{fmt.Sprintf("vector_points_%x_idx", md5.Sum([]byte(orgId)))}.Sanitize()
+}
+
+// DDL takes no parameters
+func quoteLiteral(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+func (pgv Pgv) GetStatus() (string, error) {
+	var version string
+	err := pgv.Driver.QueryRow(context.Background(), `SELECT extversion FROM pg_extension WHERE extname='vector'`).Scan(&version)
+	return "pgvector " + version, err
+}
+
+func (pgv Pgv) ListCollections() ([]string, error) {
+	collections := []string{}
+
+	rows, err := pgv.Driver.Query(context.Background(), `SELECT name FROM vector_collections ORDER BY name`)
+	if err != nil {
+		return collections, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return []string{}, err
+		}
+		collections = append(collections, name)
+	}
+
+	return collections, rows.Err()
+}
+
+func (pgv Pgv) GetCollection(orgId string) (model.CollectionInfo, error) {
+	info := model.CollectionInfo{Status: "green"}
+
+	err := pgv.Driver.QueryRow(context.Background(), `
+	SELECT vector_size, distance, (SELECT count(*) FROM vector_points WHERE collection=$1)
+	FROM vector_collections WHERE name=$1`, orgId).Scan(&info.VectorSize, &info.Distance, &info.PointsCount)
+	if err == pg.ErrNoRows {
+		return info, fmt.Errorf("collection %s not found", orgId)
+	}
+
+	return info, err
+}
+
+func (pgv Pgv) CreateCollection(orgId string, vectorSize uint64, distance string) error {
+	metric, err := qdrant.Distance(distance)
+	if err != nil {
+		return err
+	}
+
+	c := collection{Name: orgId, VectorSize: int(vectorSize), Distance: strings.ToLower(metric.String())}
+	_, err = pgv.Driver.Exec(context.Background(),
+		`INSERT INTO vector_collections (name, vector_size, distance) VALUES ($1, $2, $3)`,
+		c.Name, c.VectorSize, c.Distance)
+	if err != nil {
+		return err
+	}
+
+	return pgv.createIndex(c)
+}
+
+// payload columns are indexed by the schema
+func (pgv Pgv) CreatePayloadIndexes(orgId string) error {
+	return nil
+}
+
+func (pgv Pgv) DeleteVectorsByDocumentId(orgId string, workspaceId string, documentId string) (uint64, error) {
+	commandTag, err := pgv.Driver.Exec(context.Background(),
+		`DELETE FROM vector_points WHERE collection=$1 AND workspace_id=$2 AND document_id=$3`, orgId, workspaceId, documentId)
+
+	return uint64(commandTag.RowsAffected()), err
+}
+
+func (pgv Pgv) DeleteVectorsByWorkspaceId(orgId string, workspaceId string) (uint64, error) {
+	commandTag, err := pgv.Driver.Exec(context.Background(),
+		`DELETE FROM vector_points WHERE collection=$1 AND workspace_id=$2`, orgId, workspaceId)
+
+	return uint64(commandTag.RowsAffected()), err
+}
+
+func (pgv Pgv) ClearCollection(orgId string) (int, error) {
+	commandTag, err := pgv.Driver.Exec(context.Background(), `DELETE FROM vector_points WHERE collection=$1`, orgId)
+	if err == nil && commandTag.RowsAffected() == 0 {
+		return 0, errors.New("collection has no points")
+	}
+
+	return int(commandTag.RowsAffected()), err
+}
+
+func (pgv Pgv) DeleteCollection(orgId string) error {
+	_, err := pgv.Driver.Exec(context.Background(), `DELETE FROM vector_collections WHERE name=$1`, orgId)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgv.Driver.Exec(context.Background(), `DROP INDEX IF EXISTS `+indexName(orgId))
+	return err
+}
+
+func (pgv Pgv) SetDocumentTags(orgId string, workspaceId string, documentId string, tagIds []string) error {
+	if tagIds == nil {
+		tagIds = []string{}
+	}
+
+	_, err := pgv.Driver.Exec(context.Background(),
+		`UPDATE vector_points SET tags=$4 WHERE collection=$1 AND workspace_id=$2 AND document_id=$3`, orgId, workspaceId, documentId, tagIds)
+
+	return err
+}
+
+func (pgv Pgv) CreateSnapshot(orgId string) (model.Snapshot, error) {
+	return model.Snapshot{}, errSnapshotsUnsupported
+}
+
+func (pgv Pgv) ListSnapshots(orgId string) ([]model.Snapshot, error) {
+	return nil, errSnapshotsUnsupported
+}
+
+func (pgv Pgv) DownloadSnapshot(orgId string, snapshotName string) (io.ReadCloser, error) {
+	return nil, errSnapshotsUnsupported
+}
+
+func (pgv Pgv) RestoreSnapshot(orgId string, snapshotName string) error {
+	return errSnapshotsUnsupported
+}
+
+func (pgv Pgv) GetPointCount(orgId string) (uint32, error) {
+	info, err := pgv.GetCollection(orgId)
+	return uint32(info.PointsCount), err
+}
+
+func (pgv Pgv) CountPointsByDocument(orgId string) (map[string]map[string]model.DocumentPoints, error) {
+	counts := map[string]map[string]model.DocumentPoints{}
+
+	rows, err := pgv.Driver.Query(context.Background(), `
+	SELECT workspace_id, document_id, count(*), max(uploaded) FROM vector_points
+	WHERE collection=$1 GROUP BY workspace_id, document_id`, orgId)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workspaceId, documentId string
+		var count, uploaded int64
+		if err := rows.Scan(&workspaceId, &documentId, &count, &uploaded); err != nil {
+			return counts, err
+		}
+
+		if _, ok := counts[workspaceId]; !ok {
+			counts[workspaceId] = map[string]model.DocumentPoints{}
+		}
+		counts[workspaceId][documentId] = model.DocumentPoints{Points: count, Uploaded: time.Unix(uploaded, 0)}
+	}
+
+	return counts, rows.Err()
+}
+
+// vectors go over the wire in pgvector's text format, [1,2,3]
+func formatVector(vector []float32) string {
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		values[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+func parseVector(text string) ([]float32, error) {
+	text = strings.Trim(text, "[]")
+	if text == "" {
+		return nil, nil
+	}
+
+	values := strings.Split(text, ",")
+	vector := make([]float32, len(values))
+	for i, value := range values {
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, err
+		}
+		vector[i] = float32(v)
+	}
+
+	return vector, nil
+}
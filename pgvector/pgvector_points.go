@@ -0,0 +1,202 @@
+package pgvector
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"vector-ai/model"
+	"vector-ai/qdrant"
+
+	pg "github.com/jackc/pgx/v5"
+)
+
+const pointColumns = `id::text, workspace_id, document_id, chunk_index, chunk, hash, tags`
+
+func (pgv Pgv) GetPoint(orgId string, pointId string) (model.Chunk, error) {
+	chunks, err := pgv.GetPointsByUuid(orgId, []string{pointId})
+	if err != nil {
+		return model.Chunk{}, err
+	}
+	if len(chunks) == 0 {
+		return model.Chunk{}, fmt.Errorf("point %s not found", pointId)
+	}
+
+	return chunks[0], nil
+}
+
+func (pgv Pgv) GetPointsByUuid(orgId string, pointIds []string) ([]model.Chunk, error) {
+	rows, err := pgv.Driver.Query(context.Background(), `
+	SELECT `+pointColumns+` FROM vector_points
+	WHERE collection=$1 AND id = ANY($2::uuid[])`, orgId, pointIds)
+	if err != nil {
+		return []model.Chunk{}, err
+	}
+
+	return scanChunks(rows, false)
+}
+
+func (pgv Pgv) GetPointsByIndeces(orgId string, documentId string, indeces []int64) ([]model.Chunk, error) {
+	rows, err := pgv.Driver.Query(context.Background(), `
+	SELECT `+pointColumns+` FROM vector_points
+	WHERE collection=$1 AND document_id=$2 AND chunk_index = ANY($3::integer[])
+	ORDER BY chunk_index`, orgId, documentId, indeces)
+	if err != nil {
+		return []model.Chunk{}, err
+	}
+
+	return scanChunks(rows, false)
+}
+
+func (pgv Pgv) GetPointsByDocuments(orgId string, workspaceId string, documentIds []string) ([]model.Chunk, error) {
+	rows, err := pgv.Driver.Query(context.Background(), `
+	SELECT `+pointColumns+`, embedding::text FROM vector_points
+	WHERE collection=$1 AND workspace_id=$2 AND document_id = ANY($3::text[])
+	ORDER BY document_id, chunk_index`, orgId, workspaceId, documentIds)
+	if err != nil {
+		return []model.Chunk{}, err
+	}
+
+	return scanChunks(rows, true)
+}
+
+// Upload stores chunks under the same deterministic ids as qdrant.Qdr.Upload and
+// removes the document's points left over from a previous version
+func (pgv Pgv) Upload(orgId string, workspaceId string, documentId string, floats [][]float32, chunks []string) (string, error) {
+	ctx := context.Background()
+
+	tx, err := pgv.Driver.Begin(ctx)
+	if err != nil {
+		return fmt.Sprintf("Could not upsert points: %v", err), err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pg.Batch{}
+	pointIds := []string{}
+	uploaded := time.Now().Unix()
+	for i, vector := range floats {
+		chunk := chunks[i]
+		pointId := qdrant.PointId(documentId, i, chunk)
+		pointIds = append(pointIds, pointId)
+
+		batch.Queue(`
+		INSERT INTO vector_points (id, collection, workspace_id, document_id, chunk_index, chunk, hash, embedding, uploaded)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::vector, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			collection=EXCLUDED.collection, workspace_id=EXCLUDED.workspace_id, document_id=EXCLUDED.document_id,
+			chunk_index=EXCLUDED.chunk_index, chunk=EXCLUDED.chunk, hash=EXCLUDED.hash, embedding=EXCLUDED.embedding, uploaded=EXCLUDED.uploaded`,
+			pointId, orgId, workspaceId, documentId, i, chunk, qdrant.ChunkHash(chunk), formatVector(vector), uploaded)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Sprintf("Could not upsert points: %v", err), err
+	}
+
+	if _, err := tx.Exec(ctx, `
+	DELETE FROM vector_points
+	WHERE collection=$1 AND workspace_id=$2 AND document_id=$3 AND NOT (id = ANY($4::uuid[]))`,
+		orgId, workspaceId, documentId, pointIds); err != nil {
+		return fmt.Sprintf("Could not remove stale points: %v", err), err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Sprintf("Could not upsert points: %v", err), err
+	}
+
+	return fmt.Sprintf("Upserted %d points \n", len(floats)), nil
+}
+
+// Scroll iterates every point of a workspace, or of the whole org if workspaceId is empty
+func (pgv Pgv) Scroll(orgId string, workspaceId string, withVectors bool) qdrant.Iterator {
+	return &pageIterator{pgv: pgv, orgId: orgId, workspaceId: workspaceId, withVectors: withVectors}
+}
+
+// pageIterator pages by id, one query per page, so large orgs are never loaded at once
+type pageIterator struct {
+	pgv         Pgv
+	orgId       string
+	workspaceId string
+	withVectors bool
+	after       string
+	page        []model.Chunk
+	done        bool
+	err         error
+}
+
+func (it *pageIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	vectorColumn := "NULL::text"
+	if it.withVectors {
+		vectorColumn = "embedding::text"
+	}
+
+	// keyset paging on the primary key, from the last id of the previous page
+	args := []any{it.orgId, it.workspaceId, qdrant.ScrollPageSize}
+	after := ""
+	if it.after != "" {
+		args = append(args, it.after)
+		after = "AND id > $4::uuid"
+	}
+
+	rows, err := it.pgv.Driver.Query(context.Background(), `
+	SELECT `+pointColumns+`, `+vectorColumn+` FROM vector_points
+	WHERE collection=$1 AND ($2 = '' OR workspace_id=$2) `+after+`
+	ORDER BY id
+	LIMIT $3`, args...)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page, it.err = scanChunks(rows, true)
+	if it.err != nil || len(it.page) == 0 {
+		return false
+	}
+
+	it.after = it.page[len(it.page)-1].ID
+	it.done = len(it.page) < int(qdrant.ScrollPageSize)
+
+	return true
+}
+
+func (it *pageIterator) Page() []model.Chunk {
+	return it.page
+}
+
+func (it *pageIterator) Err() error {
+	return it.err
+}
+
+// scanChunks reads pointColumns, followed by the vector as text if withVector
+func scanChunks(rows pg.Rows, withVector bool) ([]model.Chunk, error) {
+	defer rows.Close()
+
+	chunks := []model.Chunk{}
+	for rows.Next() {
+		var chunk model.Chunk
+		var vector *string
+
+		dest := []any{&chunk.ID, &chunk.WorkspaceID, &chunk.DocumentID, &chunk.Index, &chunk.Text, &chunk.Hash, &chunk.Tags}
+		if withVector {
+			dest = append(dest, &vector)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return []model.Chunk{}, err
+		}
+
+		if vector != nil {
+			parsed, err := parseVector(*vector)
+			if err != nil {
+				return []model.Chunk{}, err
+			}
+			chunk.Vector = parsed
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
@@ -0,0 +1,144 @@
+package pgvector
+
+import (
+	"context"
+	"fmt"
+	"vector-ai/model"
+
+	pg "github.com/jackc/pgx/v5"
+)
+
+// per collection distance: the pgvector operator, the opclass indexing it, and the
+// score it maps to. Higher is closer for every metric; euclidean distance is mapped
+// to 1/(1+d) like qdrant.Memory does.
+type metric struct {
+	operator string
+	opclass  string
+	score    string
+}
+
+var metrics = map[string]metric{
+	"cosine": {operator: "<=>", opclass: "vector_cosine_ops", score: "1 - (%s)"},
+	"dot":    {operator: "<#>", opclass: "vector_ip_ops", score: "-(%s)"},
+	"euclid": {operator: "<->", opclass: "vector_l2_ops", score: "1 / (1 + (%s))"},
+}
+
+func (pgv Pgv) Vss(vector []float32, orgId string, workspaceId string, options model.VssOptions, filter model.VssFilter) ([]model.Hit, error) {
+	return pgv.searchGroups(vector, orgId, []string{workspaceId}, options, filter)
+}
+
+// OrgVss searches the whole org collection, limited to the given workspaces
+func (pgv Pgv) OrgVss(vector []float32, orgId string, workspaceIds []string, options model.VssOptions) ([]model.Hit, error) {
+	return pgv.searchGroups(vector, orgId, workspaceIds, options, model.VssFilter{})
+}
+
+func (pgv Pgv) Query(vector []float32, orgId string, workspaceId string) ([]model.Hit, error) {
+	distance, score, err := pgv.expressions(orgId)
+	if err != nil {
+		return []model.Hit{}, err
+	}
+
+	// ordering by the distance itself lets the collection's index serve the query
+	rows, err := pgv.Driver.Query(context.Background(), `
+	SELECT id::text, workspace_id, document_id, chunk_index, chunk, `+score+` AS score, NULL::text
+	FROM vector_points
+	WHERE collection=$1 AND workspace_id=$3
+	ORDER BY `+distance+`, id
+	LIMIT 10`, orgId, formatVector(vector), workspaceId)
+	if err != nil {
+		return []model.Hit{}, err
+	}
+
+	return scanHits(rows)
+}
+
+// searchGroups returns the best VssChunkLimit chunks of the best VssDocumentLimit
+// documents, flattened best score first, like qdrant's grouped search. It is an
+// exact scan over the org's points.
+func (pgv Pgv) searchGroups(vector []float32, orgId string, workspaceIds []string, options model.VssOptions, filter model.VssFilter) ([]model.Hit, error) {
+	_, score, err := pgv.expressions(orgId)
+	if err != nil {
+		return []model.Hit{}, err
+	}
+
+	vectorColumn := "NULL::text"
+	if options.MmrLambda > 0 { // needed for diversification
+		vectorColumn = "embedding::text"
+	}
+
+	rows, err := pgv.Driver.Query(context.Background(), `
+	WITH scored AS (
+		SELECT id, workspace_id, document_id, chunk_index, chunk, embedding, `+score+` AS score
+		FROM vector_points
+		WHERE collection=$1 AND workspace_id = ANY($3::text[])
+		AND (COALESCE(cardinality($4::text[]), 0) = 0 OR document_id = ANY($4::text[]))
+		AND (COALESCE(cardinality($5::text[]), 0) = 0 OR tags && $5::text[])
+	), ranked AS (
+		SELECT *,
+			row_number() OVER (PARTITION BY document_id ORDER BY score DESC, id) AS chunk_rank,
+			max(score) OVER (PARTITION BY document_id) AS best
+		FROM scored
+		WHERE $6::float8 = 0 OR score >= $6::float8
+	), documents AS (
+		SELECT document_id FROM ranked WHERE chunk_rank = 1
+		ORDER BY best DESC, document_id
+		LIMIT $7
+	)
+	SELECT id::text, workspace_id, document_id, chunk_index, chunk, score, `+vectorColumn+`
+	FROM ranked JOIN documents USING (document_id)
+	WHERE chunk_rank <= $8
+	ORDER BY score DESC, id`,
+		orgId, formatVector(vector), workspaceIds, filter.DocumentIDs, filter.TagIDs,
+		float64(options.VssScoreThreshold)/1000, options.VssDocumentLimit, options.VssChunkLimit)
+	if err != nil {
+		return []model.Hit{}, err
+	}
+
+	return scanHits(rows)
+}
+
+// expressions returns the distance and score of the query vector $2 for a collection,
+// cast to its dimension
+func (pgv Pgv) expressions(orgId string) (string, string, error) {
+	var c collection
+	err := pgv.Driver.QueryRow(context.Background(), `SELECT name, vector_size, distance FROM vector_collections WHERE name=$1`, orgId).Scan(&c.Name, &c.VectorSize, &c.Distance)
+	if err != nil {
+		return "", "", err
+	}
+
+	m, ok := metrics[c.Distance]
+	if !ok {
+		m = metrics["dot"]
+	}
+
+	distance := fmt.Sprintf("embedding::vector(%[1]d) %[2]s $2::vector(%[1]d)", c.VectorSize, m.operator)
+	return distance, fmt.Sprintf(m.score, distance), nil
+}
+
+func scanHits(rows pg.Rows) ([]model.Hit, error) {
+	defer rows.Close()
+
+	hits := []model.Hit{}
+	for rows.Next() {
+		var hit model.Hit
+		var score float64
+		var vector *string
+
+		if err := rows.Scan(&hit.ID, &hit.WorkspaceID, &hit.DocumentID, &hit.Index, &hit.Value, &score, &vector); err != nil {
+			return []model.Hit{}, err
+		}
+		hit.Score = float32(score)
+
+		if vector != nil {
+			parsed, err := parseVector(*vector)
+			if err != nil {
+				return []model.Hit{}, err
+			}
+			hit.Vector = parsed
+		}
+
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"time"
 	"vector-ai/model"
 
 	"github.com/google/uuid"
@@ -88,7 +89,8 @@ type Controls interface {
 
 	ListMessages(string) ([]model.Message, error)
 	CreateMessage(string, string, string, string, string, string, string) (model.Message, error)
-	CreateAIMessage(string, string, string, string, string, string) (model.Message, error)
+	CreateAIMessage(string, string, string, int32, string, string, string, string, string) (model.Message, error)
+	SetMessageStructured(string, []byte, bool) (model.Message, error)
 	CreateEmptyMessage(string, string, string) (model.Message, error)
 	GetMessage(string) (model.Message, error)
 	GetLastMessage(string) (model.Message, error)
@@ -101,6 +103,16 @@ type Controls interface {
 	GetTotalFileSizeAmount(string) (int64, error)
 	ClearDocuments(string) error
 	DeleteDocument(string) error
+	FlagDocumentReindex(string) error
+	SetDocumentContentHash(string, string) error
+	ListDocumentsByContentHash(string, string) ([]model.Document, error)
+	ListDocumentContentHashes(string) (map[string]string, error)
+
+	CreateChunks(string, string, []string, []string) (int64, error)
+	BackfillChunks([]model.Chunk) (int64, error)
+	SearchChunks(string, string, model.VssFilter, uint32) ([]model.Hit, error)
+	DeleteChunksByDocumentId(string) error
+	ClearChunks(string) error
 
 	ListDriveDocumentSync(string) ([]model.DriveDocumentSync, error)
 	ListDriveDocumentSyncByParentId(string, string) ([]model.DriveDocumentSync, error)
@@ -110,6 +122,10 @@ type Controls interface {
 	DeleteDriveDocumentSync(string) error
 	DeleteDriveDocumentSyncByDocumentId(string) error
 
+	// drive skipped documents
+	ListDriveSkippedDocuments(string) (map[string]time.Time, error)
+	CreateDriveSkippedDocument(string, string, string) error
+
 	ListDriveFolderSync(string) ([]model.DriveFolderSync, error)
 	GetDriveFolderSync(string) (model.DriveFolderSync, error)
 	CreateDriveFolderSync(string, string, string, string, string, string) (model.DriveFolderSync, error)
@@ -118,21 +134,31 @@ type Controls interface {
 
 	ListContextsByDocumentId(string) ([]model.Context, error)
 	ListContextsByMessageId(string) ([]model.Context, error)
-	CreateContext(string, string, string) (model.Context, error)
+	ListContextsByConversationId(string) ([]model.Context, error)
+	CreateContext(string, model.Citation) (model.Context, error)
 	GetContext(string) (model.Context, error)
 	DeleteContext(string) error
 
 	ListTemplates(string, string) ([]model.Template, error)
-	CreateTemplate(string, string, string, string, string, string, string) (model.Template, error)
+	CreateTemplate(string, string, string, string, []model.TemplateVariable, string, string, string) (model.Template, error)
 	GetTemplate(string) (model.Template, error)
-	UpdateTemplate(string, string, string) (model.Template, error)
+	UpdateTemplate(string, string, string, []model.TemplateVariable, string, string, string) (model.Template, error)
+	ListTemplateVersions(string) ([]model.TemplateVersion, error)
+	GetTemplateVersion(string, int32) (model.TemplateVersion, error)
 	DeleteTemplate(string) error
 
 	ListUsageEvents(string) ([]model.UsageEvent, error)
 	GetUsageEvent(string) (model.UsageEvent, error)
-	CreateUsageEvent(string, int64, string) (model.UsageEvent, error)
+	GetLatestUsageEventTimestamp(string) (time.Time, error)
+	ListUnreportedUsageEvents(string) ([]model.UsageEvent, error)
+	CreateUsageEvent(string, int64, model.UsageTotals, bool, string) (model.UsageEvent, error)
+	SetUsageEventTokensReported(string) error
 	DeleteUsageEvent(string) error
 
+	CreateTokenUsage(model.TokenUsage, string) (model.TokenUsage, error)
+	SumTokenUsage(model.UsageFilter) (model.UsageTotals, error)
+	ListTokenUsageBuckets(model.UsageFilter, string) ([]model.UsageBucket, error)
+
 	ListTags(string) ([]model.Tag, error)
 	CreateTag(string, string, string) (model.Tag, error)
 	GetTag(string) (model.Tag, error)
@@ -145,4 +171,11 @@ type Controls interface {
 	CreateDocumentTagAssociation(string, string, string) (model.DocumentTagAssociation, error)
 	GetDocumentTagAssociation(string, string) (model.DocumentTagAssociation, error)
 	DeleteDocumentTagAssociation(string, string) error
+
+	ListTopics(string) ([]model.Topic, error)
+	ReplaceTopics(string, []model.Topic, string) error
+	ClearTopics(string) error
+
+	ListAutoSnapshots(string) ([]model.AutoSnapshot, error)
+	CreateAutoSnapshot(string, string, string, string) error
 }
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"vector-ai/model"
+
+	"github.com/google/uuid"
+)
+
+// Snapshots taken of an org's collection before destructive operations, newest first
+func (pgx Pgx) ListAutoSnapshots(orgId string) ([]model.AutoSnapshot, error) {
+	snapshots := []model.AutoSnapshot{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, snapshot_name, operation, timestamp FROM auto_snapshots WHERE org_id=$1 ORDER BY timestamp DESC`, orgId)
+	if err != nil {
+		return []model.AutoSnapshot{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snapshot model.AutoSnapshot
+		if err := rows.Scan(&snapshot.ID, &snapshot.OrgID, &snapshot.SnapshotName, &snapshot.Operation, &snapshot.Timestamp); err != nil {
+			return []model.AutoSnapshot{}, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+func (pgx Pgx) CreateAutoSnapshot(orgId string, snapshotName string, operation string, timestamp string) error {
+	_, err := pgx.Driver.Exec(context.Background(),
+		"INSERT INTO auto_snapshots (id, org_id, snapshot_name, operation, timestamp) VALUES ($1, $2, $3, $4, $5)",
+		uuid.New(), orgId, snapshotName, operation, timestamp)
+	return err
+}
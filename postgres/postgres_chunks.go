@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"vector-ai/model"
+
+	pg "github.com/jackc/pgx/v5"
+)
+
+// Replaces every chunk of a document, mirroring what was upserted to qdrant
+func (pgx Pgx) CreateChunks(workspaceId string, documentId string, pointIds []string, chunks []string) (int64, error) {
+	ctx := context.Background()
+
+	tx, err := pgx.Driver.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM chunks WHERE document_id=$1`, documentId); err != nil {
+		return 0, err
+	}
+
+	rows := [][]any{}
+	for i, chunk := range chunks {
+		rows = append(rows, []any{pointIds[i], workspaceId, documentId, i, chunk})
+	}
+
+	copied, err := tx.CopyFrom(ctx,
+		pg.Identifier{"chunks"},
+		[]string{"point_id", "workspace_id", "document_id", "chunk_index", "text"},
+		pg.CopyFromRows(rows),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return copied, tx.Commit(ctx)
+}
+
+// Full-text search over chunk text, best matches first
+func (pgx Pgx) SearchChunks(workspaceId string, query string, filter model.VssFilter, limit uint32) ([]model.Hit, error) {
+	hits := []model.Hit{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `
+	SELECT point_id, document_id, chunk_index, text, ts_rank_cd(tsv, q) AS rank
+	FROM chunks, websearch_to_tsquery('simple', $2) q
+	WHERE workspace_id=$1 AND tsv @@ q
+	AND (COALESCE(cardinality($3::text[]), 0) = 0 OR document_id::text = ANY($3::text[]))
+	AND (COALESCE(cardinality($4::text[]), 0) = 0 OR document_id IN
+		(SELECT document_id FROM document_tag_associations WHERE tag_id::text = ANY($4::text[])))
+	ORDER BY rank DESC
+	LIMIT $5`, workspaceId, query, filter.DocumentIDs, filter.TagIDs, limit)
+
+	if err != nil {
+		return hits, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit model.Hit
+		if err := rows.Scan(&hit.ID, &hit.DocumentID, &hit.Index, &hit.Value, &hit.Score); err != nil {
+			return []model.Hit{}, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, err
+}
+
+// Mirrors chunks uploaded before the chunks table existed, leaving mirrored ones as is
+func (pgx Pgx) BackfillChunks(chunks []model.Chunk) (int64, error) {
+	batch := &pg.Batch{}
+	for _, chunk := range chunks {
+		batch.Queue(`INSERT INTO chunks (point_id, workspace_id, document_id, chunk_index, text) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (point_id) DO NOTHING`,
+			chunk.ID, chunk.WorkspaceID, chunk.DocumentID, chunk.Index, chunk.Text)
+	}
+
+	results := pgx.Driver.SendBatch(context.Background(), batch)
+	defer results.Close()
+
+	var inserted int64
+	for range chunks {
+		commandTag, err := results.Exec()
+		if err != nil {
+			return inserted, err
+		}
+		inserted += commandTag.RowsAffected()
+	}
+
+	return inserted, nil
+}
+
+func (pgx Pgx) DeleteChunksByDocumentId(documentId string) error {
+	_, err := pgx.Driver.Exec(context.Background(), `DELETE FROM chunks WHERE document_id=$1`, documentId)
+	return err
+}
+
+func (pgx Pgx) ClearChunks(workspaceId string) error {
+	_, err := pgx.Driver.Exec(context.Background(), `DELETE FROM chunks WHERE workspace_id=$1`, workspaceId)
+	return err
+}
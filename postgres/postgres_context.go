@@ -10,7 +10,7 @@ import (
 func (pgx Pgx) ListContextsByDocumentId(documentId string) ([]model.Context, error) {
 	contexts := []model.Context{}
 
-	rows, err := pgx.Driver.Query(bg.Background(), `SELECT id, document_id, message_id, point_id FROM context WHERE document_id=$1`, documentId)
+	rows, err := pgx.Driver.Query(bg.Background(), `SELECT id, document_id, message_id, point_id, position, COALESCE(span_start, 0), COALESCE(span_end, 0) FROM context WHERE document_id=$1`, documentId)
 	if err != nil {
 		return []model.Context{}, err
 	}
@@ -18,7 +18,7 @@ func (pgx Pgx) ListContextsByDocumentId(documentId string) ([]model.Context, err
 
 	for rows.Next() {
 		var context model.Context
-		if err := rows.Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID); err != nil {
+		if err := rows.Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID, &context.Position, &context.SpanStart, &context.SpanEnd); err != nil {
 			return []model.Context{}, err
 		}
 		contexts = append(contexts, context)
@@ -30,7 +30,7 @@ func (pgx Pgx) ListContextsByDocumentId(documentId string) ([]model.Context, err
 func (pgx Pgx) ListContextsByMessageId(messageId string) ([]model.Context, error) {
 	contexts := []model.Context{}
 
-	rows, err := pgx.Driver.Query(bg.Background(), `SELECT id, document_id, message_id, point_id FROM context WHERE message_id=$1`, messageId)
+	rows, err := pgx.Driver.Query(bg.Background(), `SELECT id, document_id, message_id, point_id, position, COALESCE(span_start, 0), COALESCE(span_end, 0) FROM context WHERE message_id=$1 ORDER BY position`, messageId)
 	if err != nil {
 		return []model.Context{}, err
 	}
@@ -38,7 +38,7 @@ func (pgx Pgx) ListContextsByMessageId(messageId string) ([]model.Context, error
 
 	for rows.Next() {
 		var context model.Context
-		if err := rows.Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID); err != nil {
+		if err := rows.Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID, &context.Position, &context.SpanStart, &context.SpanEnd); err != nil {
 			return []model.Context{}, err
 		}
 		contexts = append(contexts, context)
@@ -47,18 +47,40 @@ func (pgx Pgx) ListContextsByMessageId(messageId string) ([]model.Context, error
 	return contexts, err
 }
 
+// contexts of every message in a conversation, each message's in citation order
+func (pgx Pgx) ListContextsByConversationId(conversationId string) ([]model.Context, error) {
+	contexts := []model.Context{}
+
+	rows, err := pgx.Driver.Query(bg.Background(), `SELECT context.id, context.document_id, context.message_id, context.point_id, context.position, COALESCE(context.span_start, 0), COALESCE(context.span_end, 0) FROM context JOIN messages ON messages.id = context.message_id WHERE messages.conversation_id=$1 ORDER BY context.message_id, context.position`, conversationId)
+	if err != nil {
+		return []model.Context{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var context model.Context
+		if err := rows.Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID, &context.Position, &context.SpanStart, &context.SpanEnd); err != nil {
+			return []model.Context{}, err
+		}
+		contexts = append(contexts, context)
+	}
+
+	return contexts, rows.Err()
+}
+
 func (pgx Pgx) GetContext(contextId string) (model.Context, error) {
 	var context model.Context
-	if err := pgx.Driver.QueryRow(bg.Background(), "SELECT id, document_id, message_id, point_id FROM context WHERE id=$1", contextId).Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID); err != nil {
+	if err := pgx.Driver.QueryRow(bg.Background(), "SELECT id, document_id, message_id, point_id, position, COALESCE(span_start, 0), COALESCE(span_end, 0) FROM context WHERE id=$1", contextId).Scan(&context.ID, &context.DocumentID, &context.MessageID, &context.PointID, &context.Position, &context.SpanStart, &context.SpanEnd); err != nil {
 		return context, err
 	}
 	return context, nil
 }
 
-func (pgx Pgx) CreateContext(documentId string, messageId string, pointId string) (model.Context, error) {
+func (pgx Pgx) CreateContext(messageId string, citation model.Citation) (model.Context, error) {
 	uuid := uuid.New()
 	commandTag, err := pgx.Driver.Exec(bg.Background(),
-		"INSERT INTO context (id, document_id, message_id, point_id) VALUES ($1, $2, $3, $4)", uuid, documentId, messageId, pointId)
+		"INSERT INTO context (id, document_id, message_id, point_id, position, span_start, span_end) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		uuid, citation.DocumentID, messageId, citation.PointID, citation.Position, citation.Start, citation.End)
 
 	if err != nil || commandTag.RowsAffected() != 1 {
 		var context model.Context
@@ -10,7 +10,7 @@ import (
 func (pgx Pgx) ListDocuments(workspaceId string) ([]model.Document, error) {
 	files := []model.Document{}
 
-	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp FROM documents WHERE workspace_id=$1`, workspaceId)
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp, reindex FROM documents WHERE workspace_id=$1`, workspaceId)
 	if err != nil {
 		return []model.Document{}, err
 	}
@@ -18,7 +18,7 @@ func (pgx Pgx) ListDocuments(workspaceId string) ([]model.Document, error) {
 
 	for rows.Next() {
 		var file model.Document
-		if err := rows.Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp); err != nil {
+		if err := rows.Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp, &file.Reindex); err != nil {
 			return []model.Document{}, err
 		}
 		files = append(files, file)
@@ -29,7 +29,7 @@ func (pgx Pgx) ListDocuments(workspaceId string) ([]model.Document, error) {
 
 func (pgx Pgx) GetDocument(fileId string) (model.Document, error) {
 	var file model.Document
-	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp FROM documents WHERE id=$1`, fileId).Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp); err != nil {
+	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp, reindex FROM documents WHERE id=$1`, fileId).Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp, &file.Reindex); err != nil {
 		return file, err
 	}
 	return file, nil
@@ -69,7 +69,7 @@ func (pgx Pgx) CreateDocument(uuid uuid.UUID, workspaceId string, fileName strin
 
 func (pgx Pgx) UpdateDocument(documentId string, fileName string, fileSize int64, vectors int64, chunkSize int64, timestamp string) (model.Document, error) {
 	commandTag, err := pgx.Driver.Exec(context.Background(),
-		"UPDATE documents SET name=$1, size=$2, vectors=$3, chunk_size=$4, timestamp=$5 WHERE id=$6", fileName, fileSize, vectors, chunkSize, timestamp, documentId)
+		"UPDATE documents SET name=$1, size=$2, vectors=$3, chunk_size=$4, timestamp=$5, reindex=false WHERE id=$6", fileName, fileSize, vectors, chunkSize, timestamp, documentId)
 
 	if err != nil || commandTag.RowsAffected() != 1 {
 		var document model.Document
@@ -79,6 +79,65 @@ func (pgx Pgx) UpdateDocument(documentId string, fileName string, fileSize int64
 	return pgx.GetDocument(documentId)
 }
 
+// FlagDocumentReindex marks a document for reindexing. Drive synced documents also get
+// their last_modified reset so the next sync treats them as updated.
+func (pgx Pgx) FlagDocumentReindex(documentId string) error {
+	_, err := pgx.Driver.Exec(context.Background(), "UPDATE documents SET reindex=true WHERE id=$1", documentId)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgx.Driver.Exec(context.Background(), "UPDATE drive_document_sync SET last_modified='epoch' WHERE document_id=$1", documentId)
+	return err
+}
+
+func (pgx Pgx) SetDocumentContentHash(documentId string, contentHash string) error {
+	_, err := pgx.Driver.Exec(context.Background(), "UPDATE documents SET content_hash=NULLIF($1, '') WHERE id=$2", contentHash, documentId)
+	return err
+}
+
+// Documents of a workspace with the given content hash
+func (pgx Pgx) ListDocumentsByContentHash(workspaceId string, contentHash string) ([]model.Document, error) {
+	files := []model.Document{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp, reindex FROM documents WHERE workspace_id=$1 AND content_hash=$2`, workspaceId, contentHash)
+	if err != nil {
+		return []model.Document{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file model.Document
+		if err := rows.Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp, &file.Reindex); err != nil {
+			return []model.Document{}, err
+		}
+		files = append(files, file)
+	}
+
+	return files, err
+}
+
+// content hashes by documentId, documents uploaded before hashing are left out
+func (pgx Pgx) ListDocumentContentHashes(workspaceId string) (map[string]string, error) {
+	hashes := map[string]string{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, content_hash FROM documents WHERE workspace_id=$1 AND content_hash IS NOT NULL`, workspaceId)
+	if err != nil {
+		return hashes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, contentHash string
+		if err := rows.Scan(&id, &contentHash); err != nil {
+			return hashes, err
+		}
+		hashes[id] = contentHash
+	}
+
+	return hashes, err
+}
+
 func (pgx Pgx) ClearDocuments(workspaceId string) error {
 	commandTag, err := pgx.Driver.Exec(context.Background(), "DELETE FROM documents WHERE workspace_id=$1", workspaceId)
 	if err != nil || commandTag.RowsAffected() != 1 {
@@ -31,7 +31,7 @@ func (pgx Pgx) ListDocumentsByTagId(tagId string) ([]model.Document, error) {
 	documents := []model.Document{}
 
 	rows, err := pgx.Driver.Query(context.Background(), `
-	SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp FROM documents
+	SELECT id, workspace_id, name, mime_type, size, vectors, chunk_size, timestamp, reindex FROM documents
 	WHERE id=ANY 
 	(SELECT document_id FROM document_tag_associations WHERE tag_id=$1)`, tagId)
 
@@ -42,7 +42,7 @@ func (pgx Pgx) ListDocumentsByTagId(tagId string) ([]model.Document, error) {
 
 	for rows.Next() {
 		var file model.Document
-		if err := rows.Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp); err != nil {
+		if err := rows.Scan(&file.ID, &file.WorkspaceID, &file.Name, &file.MIMEType, &file.Size, &file.Vectors, &file.ChunkSize, &file.Timestamp, &file.Reindex); err != nil {
 			return []model.Document{}, err
 		}
 		documents = append(documents, file)
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// Last modified time of each Drive file a workspace sync skipped as a duplicate, by driveId
+func (pgx Pgx) ListDriveSkippedDocuments(workspaceId string) (map[string]time.Time, error) {
+	skipped := map[string]time.Time{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT drive_id, last_modified FROM drive_skipped_document WHERE workspace_id=$1`, workspaceId)
+	if err != nil {
+		return skipped, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var driveId string
+		var lastModified time.Time
+		if err := rows.Scan(&driveId, &lastModified); err != nil {
+			return map[string]time.Time{}, err
+		}
+		skipped[driveId] = lastModified
+	}
+
+	return skipped, rows.Err()
+}
+
+func (pgx Pgx) CreateDriveSkippedDocument(workspaceId string, driveId string, lastModified string) error {
+	_, err := pgx.Driver.Exec(context.Background(), `
+	INSERT INTO drive_skipped_document (workspace_id, drive_id, last_modified) VALUES ($1, $2, $3)
+	ON CONFLICT (workspace_id, drive_id) DO UPDATE SET last_modified=EXCLUDED.last_modified`, workspaceId, driveId, lastModified)
+	return err
+}
@@ -11,7 +11,7 @@ import (
 func (pgx Pgx) ListMessages(conversationId string) ([]model.Message, error) {
 	messages := []model.Message{}
 
-	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, timestamp FROM messages WHERE conversation_id=$1 ORDER BY timestamp ASC
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, COALESCE(model, ''), template_version, structured, structured_valid, timestamp FROM messages WHERE conversation_id=$1 ORDER BY timestamp ASC
 	`, conversationId)
 	if err != nil {
 		return []model.Message{}, err
@@ -20,7 +20,7 @@ func (pgx Pgx) ListMessages(conversationId string) ([]model.Message, error) {
 
 	for rows.Next() {
 		var message model.Message
-		if err := rows.Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Timestamp); err != nil {
+		if err := rows.Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Model, &message.TemplateVersion, &message.Structured, &message.StructuredValid, &message.Timestamp); err != nil {
 			return []model.Message{}, err
 		}
 		messages = append(messages, message)
@@ -31,7 +31,7 @@ func (pgx Pgx) ListMessages(conversationId string) ([]model.Message, error) {
 
 func (pgx Pgx) GetMessage(messageId string) (model.Message, error) {
 	var message model.Message
-	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, timestamp FROM messages WHERE id=$1`, messageId).Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Timestamp); err != nil {
+	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, COALESCE(model, ''), template_version, structured, structured_valid, timestamp FROM messages WHERE id=$1`, messageId).Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Model, &message.TemplateVersion, &message.Structured, &message.StructuredValid, &message.Timestamp); err != nil {
 		return message, err
 	}
 	return message, nil
@@ -39,7 +39,7 @@ func (pgx Pgx) GetMessage(messageId string) (model.Message, error) {
 
 func (pgx Pgx) GetLastMessage(workspaceId string) (model.Message, error) {
 	var message model.Message
-	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, timestamp FROM messages WHERE workspace_id=$1 ORDER BY timestamp DESC LIMIT 1`, workspaceId).Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Timestamp); err != nil {
+	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, workspace_id, conversation_id, template_id, text, author_type, author_name, COALESCE(model, ''), template_version, structured, structured_valid, timestamp FROM messages WHERE workspace_id=$1 ORDER BY timestamp DESC LIMIT 1`, workspaceId).Scan(&message.ID, &message.WorkspaceID, &message.ConversationID, &message.TemplateID, &message.Text, &message.AuthorType, &message.AuthorName, &message.Model, &message.TemplateVersion, &message.Structured, &message.StructuredValid, &message.Timestamp); err != nil {
 
 		return model.Message{
 			AuthorName: "SYSTEM",
@@ -64,11 +64,11 @@ func (pgx Pgx) CreateMessage(workspaceId string, conversationId string, template
 	return pgx.GetMessage(uuid.String())
 }
 
-func (pgx Pgx) CreateAIMessage(workspaceId string, conversationId string, text string, authorType string, authorName string, timestamp string) (model.Message, error) {
+func (pgx Pgx) CreateAIMessage(workspaceId string, conversationId string, templateId string, templateVersion int32, text string, authorType string, authorName string, modelName string, timestamp string) (model.Message, error) {
 	uuid := uuid.New()
 
 	commandTag, err := pgx.Driver.Exec(context.Background(),
-		"INSERT INTO messages (id, workspace_id, conversation_id, template_id, text, author_type, author_name, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", uuid, workspaceId, conversationId, nil, text, authorType, authorName, timestamp)
+		"INSERT INTO messages (id, workspace_id, conversation_id, template_id, template_version, text, author_type, author_name, model, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", uuid, workspaceId, conversationId, templateId, templateVersion, text, authorType, authorName, modelName, timestamp)
 
 	if err != nil || commandTag.RowsAffected() != 1 {
 		var message model.Message
@@ -78,6 +78,17 @@ func (pgx Pgx) CreateAIMessage(workspaceId string, conversationId string, text s
 	return pgx.GetMessage(uuid.String())
 }
 
+// Stores the JSON parsed from an AI message's YAML, valid is false when it never
+// matched the response schema
+func (pgx Pgx) SetMessageStructured(messageId string, structured []byte, valid bool) (model.Message, error) {
+	_, err := pgx.Driver.Exec(context.Background(), "UPDATE messages SET structured=$1, structured_valid=$2 WHERE id=$3", structured, valid, messageId)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	return pgx.GetMessage(messageId)
+}
+
 func (pgx Pgx) CreateEmptyMessage(workspaceId string, conversationId string, timestamp string) (model.Message, error) {
 	uuid := uuid.New()
 
@@ -10,7 +10,7 @@ import (
 func (pgx Pgx) ListTemplates(orgId string, workspaceId string) ([]model.Template, error) {
 	templates := []model.Template{}
 
-	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, workspace_id, name, text, user_id, author_name, created_at FROM templates WHERE org_id=$1 AND workspace_id=$2`, orgId, workspaceId)
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, workspace_id, name, text, variables, version, user_id, author_name, created_at FROM templates WHERE org_id=$1 AND workspace_id=$2`, orgId, workspaceId)
 	if err != nil {
 		return []model.Template{}, err
 	}
@@ -18,7 +18,7 @@ func (pgx Pgx) ListTemplates(orgId string, workspaceId string) ([]model.Template
 
 	for rows.Next() {
 		var template model.Template
-		if err := rows.Scan(&template.ID, &template.OrgID, &template.WorkspaceID, &template.Name, &template.Text, &template.UserID, &template.AuthorName, &template.CreatedAt); err != nil {
+		if err := rows.Scan(&template.ID, &template.OrgID, &template.WorkspaceID, &template.Name, &template.Text, &template.Variables, &template.Version, &template.UserID, &template.AuthorName, &template.CreatedAt); err != nil {
 			return []model.Template{}, err
 		}
 		templates = append(templates, template)
@@ -29,39 +29,111 @@ func (pgx Pgx) ListTemplates(orgId string, workspaceId string) ([]model.Template
 
 func (pgx Pgx) GetTemplate(templateId string) (model.Template, error) {
 	var template model.Template
-	if err := pgx.Driver.QueryRow(context.Background(), "SELECT id, org_id, workspace_id, name, text, user_id, author_name, created_at FROM templates WHERE id=$1", templateId).Scan(&template.ID, &template.OrgID, &template.WorkspaceID, &template.Name, &template.Text, &template.UserID, &template.AuthorName, &template.CreatedAt); err != nil {
+	if err := pgx.Driver.QueryRow(context.Background(), "SELECT id, org_id, workspace_id, name, text, variables, version, user_id, author_name, created_at FROM templates WHERE id=$1", templateId).Scan(&template.ID, &template.OrgID, &template.WorkspaceID, &template.Name, &template.Text, &template.Variables, &template.Version, &template.UserID, &template.AuthorName, &template.CreatedAt); err != nil {
 		return template, err
 	}
 	return template, nil
 }
 
-func (pgx Pgx) CreateTemplate(orgId string, workspaceId string, templateName string, text string, userId string, authorName string, timestamp string) (model.Template, error) {
+// Creates a template at version 1
+func (pgx Pgx) CreateTemplate(orgId string, workspaceId string, templateName string, text string, variables []model.TemplateVariable, userId string, authorName string, timestamp string) (model.Template, error) {
+	ctx := context.Background()
+	if variables == nil {
+		variables = []model.TemplateVariable{}
+	}
+
+	tx, err := pgx.Driver.Begin(ctx)
+	if err != nil {
+		return model.Template{}, err
+	}
+	defer tx.Rollback(ctx)
 
 	uuid := uuid.New()
-	commandTag, err := pgx.Driver.Exec(context.Background(),
-		"INSERT INTO templates (id, org_id, workspace_id, name, text, user_id, author_name, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", uuid, orgId, workspaceId, templateName, text, userId, authorName, timestamp)
+	commandTag, err := tx.Exec(ctx,
+		"INSERT INTO templates (id, org_id, workspace_id, name, text, variables, version, user_id, author_name, created_at) VALUES ($1, $2, $3, $4, $5, $6, 1, $7, $8, $9)", uuid, orgId, workspaceId, templateName, text, variables, userId, authorName, timestamp)
 
 	if err != nil || commandTag.RowsAffected() != 1 {
 		var template model.Template
 		return template, err
 	}
 
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO template_versions (template_id, version, name, text, variables, user_id, author_name, created_at) VALUES ($1, 1, $2, $3, $4, $5, $6, $7)", uuid, templateName, text, variables, userId, authorName, timestamp); err != nil {
+		return model.Template{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return model.Template{}, err
+	}
+
 	return pgx.GetTemplate(uuid.String())
 }
 
-func (pgx Pgx) UpdateTemplate(templateId string, templateName string, templateText string) (model.Template, error) {
-	commandTag, err := pgx.Driver.Exec(context.Background(),
-		"UPDATE templates SET name=$1, text=$2 WHERE id=$3", templateName, templateText, templateId)
+// Updates a template as its next version, keeping the previous ones
+func (pgx Pgx) UpdateTemplate(templateId string, templateName string, templateText string, variables []model.TemplateVariable, userId string, authorName string, timestamp string) (model.Template, error) {
+	ctx := context.Background()
+	if variables == nil {
+		variables = []model.TemplateVariable{}
+	}
+
+	tx, err := pgx.Driver.Begin(ctx)
+	if err != nil {
+		return model.Template{}, err
+	}
+	defer tx.Rollback(ctx)
 
-	if err != nil || commandTag.RowsAffected() != 1 {
-		var template model.Template
-		return template, err
+	var version int32
+	if err := tx.QueryRow(ctx,
+		"UPDATE templates SET name=$1, text=$2, variables=$3, version=version+1 WHERE id=$4 RETURNING version", templateName, templateText, variables, templateId).Scan(&version); err != nil {
+		return model.Template{}, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO template_versions (template_id, version, name, text, variables, user_id, author_name, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", templateId, version, templateName, templateText, variables, userId, authorName, timestamp); err != nil {
+		return model.Template{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return model.Template{}, err
 	}
 
 	return pgx.GetTemplate(templateId)
 }
 
+// Versions of a template, newest first
+func (pgx Pgx) ListTemplateVersions(templateId string) ([]model.TemplateVersion, error) {
+	versions := []model.TemplateVersion{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT template_id, version, name, text, variables, user_id, author_name, created_at FROM template_versions WHERE template_id=$1 ORDER BY version DESC`, templateId)
+	if err != nil {
+		return []model.TemplateVersion{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version model.TemplateVersion
+		if err := rows.Scan(&version.TemplateID, &version.Version, &version.Name, &version.Text, &version.Variables, &version.UserID, &version.AuthorName, &version.CreatedAt); err != nil {
+			return []model.TemplateVersion{}, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, err
+}
+
+func (pgx Pgx) GetTemplateVersion(templateId string, version int32) (model.TemplateVersion, error) {
+	var templateVersion model.TemplateVersion
+	if err := pgx.Driver.QueryRow(context.Background(), "SELECT template_id, version, name, text, variables, user_id, author_name, created_at FROM template_versions WHERE template_id=$1 AND version=$2", templateId, version).Scan(&templateVersion.TemplateID, &templateVersion.Version, &templateVersion.Name, &templateVersion.Text, &templateVersion.Variables, &templateVersion.UserID, &templateVersion.AuthorName, &templateVersion.CreatedAt); err != nil {
+		return templateVersion, err
+	}
+	return templateVersion, nil
+}
+
 func (pgx Pgx) DeleteTemplate(templateId string) error {
+	if _, err := pgx.Driver.Exec(context.Background(), "DELETE FROM template_versions WHERE template_id=$1", templateId); err != nil {
+		return err
+	}
+
 	commandTag, err := pgx.Driver.Exec(context.Background(), "DELETE FROM templates WHERE id=$1", templateId)
 	if err != nil || commandTag.RowsAffected() != 1 {
 		return err
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"vector-ai/model"
+
+	"github.com/google/uuid"
+)
+
+func (pgx Pgx) CreateTokenUsage(usage model.TokenUsage, timestamp string) (model.TokenUsage, error) {
+	uuid := uuid.New()
+
+	commandTag, err := pgx.Driver.Exec(context.Background(),
+		`INSERT INTO token_usage (id, org_id, workspace_id, conversation_id, message_id, document_id, user_id, operation, model, prompt_tokens, completion_tokens, embedding_tokens, cost_micros, timestamp)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14)`,
+		uuid, usage.OrgID, usage.WorkspaceID, usage.ConversationID, usage.MessageID, usage.DocumentID, usage.UserID,
+		usage.Operation, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.EmbeddingTokens, usage.CostMicros, timestamp)
+
+	if err != nil || commandTag.RowsAffected() != 1 {
+		return model.TokenUsage{}, err
+	}
+
+	usage.ID = uuid.String()
+	return usage, nil
+}
+
+// Totals of the usage matching filter
+func (pgx Pgx) SumTokenUsage(filter model.UsageFilter) (model.UsageTotals, error) {
+	var totals model.UsageTotals
+
+	where, args := usageWhere(filter)
+	err := pgx.Driver.QueryRow(context.Background(),
+		`SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(embedding_tokens), 0), COALESCE(SUM(cost_micros), 0)
+		FROM token_usage WHERE `+where, args...).
+		Scan(&totals.Calls, &totals.PromptTokens, &totals.CompletionTokens, &totals.EmbeddingTokens, &totals.CostMicros)
+
+	return totals, err
+}
+
+// Usage matching filter per interval ("hour", "day", "week" or "month"), model and operation
+func (pgx Pgx) ListTokenUsageBuckets(filter model.UsageFilter, interval string) ([]model.UsageBucket, error) {
+	buckets := []model.UsageBucket{}
+
+	where, args := usageWhere(filter)
+	args = append(args, interval)
+	rows, err := pgx.Driver.Query(context.Background(), fmt.Sprintf(
+		`SELECT date_trunc($%d, timestamp) AS start, model, operation, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(embedding_tokens), SUM(cost_micros)
+		FROM token_usage WHERE %s GROUP BY start, model, operation ORDER BY start ASC, model, operation`, len(args), where), args...)
+	if err != nil {
+		return []model.UsageBucket{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket model.UsageBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Model, &bucket.Operation, &bucket.Calls, &bucket.PromptTokens, &bucket.CompletionTokens, &bucket.EmbeddingTokens, &bucket.CostMicros); err != nil {
+			return []model.UsageBucket{}, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+func usageWhere(filter model.UsageFilter) (string, []any) {
+	where := `org_id=$1 AND timestamp >= $2 AND timestamp < $3`
+	args := []any{filter.OrgID, filter.From, filter.To}
+
+	if filter.WorkspaceID != "" {
+		args = append(args, filter.WorkspaceID)
+		where += fmt.Sprintf(` AND workspace_id=$%d`, len(args))
+	}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		where += fmt.Sprintf(` AND user_id=$%d`, len(args))
+	}
+
+	return where, args
+}
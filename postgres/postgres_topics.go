@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"vector-ai/model"
+
+	"github.com/google/uuid"
+)
+
+// Replaces a workspace's topics with the result of a new clustering run
+func (pgx Pgx) ReplaceTopics(workspaceId string, topics []model.Topic, timestamp string) error {
+	ctx := context.Background()
+
+	tx, err := pgx.Driver.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM topics WHERE workspace_id=$1`, workspaceId); err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		topicId := uuid.New()
+		if _, err := tx.Exec(ctx, `INSERT INTO topics (id, workspace_id, title, chunks, timestamp) VALUES ($1, $2, $3, $4, $5)`,
+			topicId, workspaceId, topic.Title, topic.Chunks, timestamp); err != nil {
+			return err
+		}
+
+		for _, document := range topic.Documents {
+			if _, err := tx.Exec(ctx, `INSERT INTO topic_documents (topic_id, document_id, chunks) VALUES ($1, $2, $3)`,
+				topicId, document.DocumentID, document.Chunks); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (pgx Pgx) ClearTopics(workspaceId string) error {
+	_, err := pgx.Driver.Exec(context.Background(), `DELETE FROM topics WHERE workspace_id=$1`, workspaceId)
+	return err
+}
+
+// Topics of a workspace, largest first, with their documents
+func (pgx Pgx) ListTopics(workspaceId string) ([]model.Topic, error) {
+	topics := []model.Topic{}
+
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, workspace_id, title, chunks, timestamp FROM topics WHERE workspace_id=$1 ORDER BY chunks DESC`, workspaceId)
+	if err != nil {
+		return []model.Topic{}, err
+	}
+	defer rows.Close()
+
+	index := map[string]int{}
+	for rows.Next() {
+		var topic model.Topic
+		if err := rows.Scan(&topic.ID, &topic.WorkspaceID, &topic.Title, &topic.Chunks, &topic.Timestamp); err != nil {
+			return []model.Topic{}, err
+		}
+		topic.Documents = []model.TopicDocument{}
+		index[topic.ID] = len(topics)
+		topics = append(topics, topic)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.Topic{}, err
+	}
+
+	documentRows, err := pgx.Driver.Query(context.Background(), `
+	SELECT td.topic_id, td.document_id, d.name, td.chunks
+	FROM topic_documents td
+	JOIN topics t ON t.id = td.topic_id
+	JOIN documents d ON d.id = td.document_id
+	WHERE t.workspace_id=$1
+	ORDER BY td.chunks DESC`, workspaceId)
+	if err != nil {
+		return []model.Topic{}, err
+	}
+	defer documentRows.Close()
+
+	for documentRows.Next() {
+		var topicId string
+		var document model.TopicDocument
+		if err := documentRows.Scan(&topicId, &document.DocumentID, &document.Name, &document.Chunks); err != nil {
+			return []model.Topic{}, err
+		}
+		if i, ok := index[topicId]; ok {
+			topics[i].Documents = append(topics[i].Documents, document)
+		}
+	}
+
+	return topics, documentRows.Err()
+}
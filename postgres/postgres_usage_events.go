@@ -2,45 +2,72 @@ package postgres
 
 import (
 	"context"
+	"time"
 	"vector-ai/model"
 
 	"github.com/google/uuid"
+	pg "github.com/jackc/pgx/v5"
 )
 
 func (pgx Pgx) ListUsageEvents(orgId string) ([]model.UsageEvent, error) {
-	usageEvents := []model.UsageEvent{}
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, total_file_size_amount, calls, prompt_tokens, completion_tokens, embedding_tokens, cost_micros, tokens_reported, timestamp FROM usage_events WHERE org_id=$1 ORDER BY timestamp ASC
+	`, orgId)
+	if err != nil {
+		return []model.UsageEvent{}, err
+	}
+
+	return scanUsageEvents(rows)
+}
 
-	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, total_file_size_amount, timestamp FROM usage_events WHERE org_id=$1 ORDER BY timestamp ASC
+// Usage events of an org whose tokens Stripe has not confirmed, oldest first
+func (pgx Pgx) ListUnreportedUsageEvents(orgId string) ([]model.UsageEvent, error) {
+	rows, err := pgx.Driver.Query(context.Background(), `SELECT id, org_id, total_file_size_amount, calls, prompt_tokens, completion_tokens, embedding_tokens, cost_micros, tokens_reported, timestamp FROM usage_events WHERE org_id=$1 AND NOT tokens_reported ORDER BY timestamp ASC
 	`, orgId)
 	if err != nil {
 		return []model.UsageEvent{}, err
 	}
+
+	return scanUsageEvents(rows)
+}
+
+func scanUsageEvents(rows pg.Rows) ([]model.UsageEvent, error) {
+	usageEvents := []model.UsageEvent{}
 	defer rows.Close()
 
 	for rows.Next() {
 		var usageEvent model.UsageEvent
-		if err := rows.Scan(&usageEvent.ID, &usageEvent.OrgID, &usageEvent.TotalFileSizeAmount, &usageEvent.Timestamp); err != nil {
+		if err := rows.Scan(&usageEvent.ID, &usageEvent.OrgID, &usageEvent.TotalFileSizeAmount, &usageEvent.Calls, &usageEvent.PromptTokens, &usageEvent.CompletionTokens, &usageEvent.EmbeddingTokens, &usageEvent.CostMicros, &usageEvent.TokensReported, &usageEvent.Timestamp); err != nil {
 			return []model.UsageEvent{}, err
 		}
 		usageEvents = append(usageEvents, usageEvent)
 	}
 
-	return usageEvents, err
+	return usageEvents, rows.Err()
 }
 
 func (pgx Pgx) GetUsageEvent(usageEventId string) (model.UsageEvent, error) {
 	var usageEvent model.UsageEvent
-	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, org_id, total_file_size_amount, timestamp FROM usage_events WHERE id=$1`, usageEventId).Scan(&usageEvent.ID, &usageEvent.OrgID, &usageEvent.TotalFileSizeAmount, &usageEvent.Timestamp); err != nil {
+	if err := pgx.Driver.QueryRow(context.Background(), `SELECT id, org_id, total_file_size_amount, calls, prompt_tokens, completion_tokens, embedding_tokens, cost_micros, tokens_reported, timestamp FROM usage_events WHERE id=$1`, usageEventId).Scan(&usageEvent.ID, &usageEvent.OrgID, &usageEvent.TotalFileSizeAmount, &usageEvent.Calls, &usageEvent.PromptTokens, &usageEvent.CompletionTokens, &usageEvent.EmbeddingTokens, &usageEvent.CostMicros, &usageEvent.TokensReported, &usageEvent.Timestamp); err != nil {
 		return usageEvent, err
 	}
 	return usageEvent, nil
 }
 
-func (pgx Pgx) CreateUsageEvent(orgId string, totalFileSizeAmount int64, timestamp string) (model.UsageEvent, error) {
+// Time of an org's latest usage event, the epoch if it has none
+func (pgx Pgx) GetLatestUsageEventTimestamp(orgId string) (time.Time, error) {
+	var timestamp time.Time
+	err := pgx.Driver.QueryRow(context.Background(), `SELECT COALESCE(MAX(timestamp), 'epoch') FROM usage_events WHERE org_id=$1`, orgId).Scan(&timestamp)
+	return timestamp, err
+}
+
+// CreateUsageEvent records a usage event, tokensReported false while Stripe still
+// has to be sent its tokens
+func (pgx Pgx) CreateUsageEvent(orgId string, totalFileSizeAmount int64, tokens model.UsageTotals, tokensReported bool, timestamp string) (model.UsageEvent, error) {
 	uuid := uuid.New()
 
 	commandTag, err := pgx.Driver.Exec(context.Background(),
-		"INSERT INTO usage_events (id, org_id, total_file_size_amount, timestamp) VALUES ($1, $2, $3, $4)", uuid, orgId, totalFileSizeAmount, timestamp)
+		"INSERT INTO usage_events (id, org_id, total_file_size_amount, calls, prompt_tokens, completion_tokens, embedding_tokens, cost_micros, tokens_reported, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		uuid, orgId, totalFileSizeAmount, tokens.Calls, tokens.PromptTokens, tokens.CompletionTokens, tokens.EmbeddingTokens, tokens.CostMicros, tokensReported, timestamp)
 
 	if err != nil || commandTag.RowsAffected() != 1 {
 		var usageEvent model.UsageEvent
@@ -50,6 +77,11 @@ func (pgx Pgx) CreateUsageEvent(orgId string, totalFileSizeAmount int64, timesta
 	return pgx.GetUsageEvent(uuid.String())
 }
 
+func (pgx Pgx) SetUsageEventTokensReported(usageEventId string) error {
+	_, err := pgx.Driver.Exec(context.Background(), "UPDATE usage_events SET tokens_reported=TRUE WHERE id=$1", usageEventId)
+	return err
+}
+
 func (pgx Pgx) DeleteUsageEvent(usageEventId string) error {
 	commandTag, err := pgx.Driver.Exec(context.Background(), "DELETE FROM usage_events WHERE id=$1", usageEventId)
 	if err != nil || commandTag.RowsAffected() != 1 {
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"vector-ai/constants"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// workspace llmProvider values
+const (
+	ProviderOpenAI           = 0
+	ProviderAnthropic        = 1
+	ProviderOpenAICompatible = 2 // local servers speaking the OpenAI API, e.g. vLLM or Ollama
+	ProviderFake             = 3
+)
+
+// Models lists the chat models a workspace can pick with llmModel, by provider and
+// model id. Ids are stored in workspace configs: never reuse or renumber one, give
+// new models the next free id. OpenAI-compatible endpoints serve the single model
+// named by LLM_COMPATIBLE_MODEL.
+var Models = map[uint32]map[uint32]string{
+	ProviderOpenAI: {
+		0: constants.LLM,
+		1: "gpt-4o-mini",
+		2: "gpt-4-turbo",
+	},
+	ProviderAnthropic: {
+		0: "claude-3-5-sonnet-20240620",
+		1: "claude-3-haiku-20240307",
+		2: "claude-3-opus-20240229",
+	},
+	ProviderFake: {
+		0: "fake",
+	},
+}
+
+// New returns the chat model for a workspace's llmProvider and llmModel, and the
+// name of the model it runs
+func New(provider uint32, modelId uint32) (llms.Model, string, error) {
+	name, err := modelName(provider, modelId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch provider {
+	case ProviderOpenAI:
+		llm, err := openai.New(openai.WithModel(name))
+		return llm, name, err
+	case ProviderAnthropic:
+		llm, err := anthropic.New(anthropic.WithModel(name))
+		return llm, name, err
+	case ProviderOpenAICompatible:
+		token := os.Getenv("LLM_COMPATIBLE_API_KEY")
+		if token == "" {
+			token = "none" // local servers usually ignore it, the client requires one
+		}
+		llm, err := openai.New(openai.WithBaseURL(os.Getenv("LLM_COMPATIBLE_URL")), openai.WithModel(name), openai.WithToken(token))
+		return llm, name, err
+	case ProviderFake:
+		if !FakeAllowed() {
+			return nil, "", fmt.Errorf("the fake llm provider needs LLM_FAKE_RESPONSES or the memory store")
+		}
+		return NewFake(), name, nil
+	default:
+		return nil, "", fmt.Errorf("unknown llm provider %d", provider)
+	}
+}
+
+// Offline reports whether the memory store is selected, which runs without any
+// provider: workspaces default to ProviderFake and texts are embedded by Fake
+func Offline() bool {
+	return os.Getenv("VECTOR_STORE") == "memory"
+}
+
+// Default is the llmProvider new workspaces start with
+func Default() uint32 {
+	if Offline() {
+		return ProviderFake
+	}
+	return ProviderOpenAI
+}
+
+// NewEmbedder returns the embedder for uploads and queries
+func NewEmbedder() (*embeddings.EmbedderImpl, error) {
+	if Offline() {
+		return embeddings.NewEmbedder(NewFake())
+	}
+
+	llm, err := openai.New(openai.WithModel(constants.LLM), openai.WithEmbeddingModel(constants.Embedder))
+	if err != nil {
+		return nil, err
+	}
+	return embeddings.NewEmbedder(llm)
+}
+
+// FakeAllowed reports whether workspaces may pick ProviderFake: when its responses are
+// scripted, or when running offline on the memory store
+func FakeAllowed() bool {
+	return os.Getenv("LLM_FAKE_RESPONSES") != "" || Offline()
+}
+
+// CallOptions applies a workspace's llmTemperature, in hundredths. 0 keeps the
+// provider's default.
+func CallOptions(temperature uint32) []llms.CallOption {
+	if temperature == 0 {
+		return nil
+	}
+	return []llms.CallOption{llms.WithTemperature(float64(temperature) / 100)}
+}
+
+// ValidModel checks that a workspace's llmProvider offers its llmModel
+func ValidModel(provider uint32, modelId uint32) error {
+	if provider == ProviderOpenAICompatible {
+		return nil // llmModel is ignored
+	}
+
+	models, ok := Models[provider]
+	if !ok {
+		return fmt.Errorf("unknown llm provider %d", provider)
+	}
+	if _, ok := models[modelId]; !ok {
+		return fmt.Errorf("unknown model %d for llm provider %d", modelId, provider)
+	}
+	return nil
+}
+
+func modelName(provider uint32, modelId uint32) (string, error) {
+	if provider == ProviderOpenAICompatible {
+		name := os.Getenv("LLM_COMPATIBLE_MODEL")
+		if name == "" {
+			return "", fmt.Errorf("LLM_COMPATIBLE_MODEL is not set")
+		}
+		return name, nil
+	}
+
+	if err := ValidModel(provider, modelId); err != nil {
+		return "", err
+	}
+	return Models[provider][modelId], nil
+}
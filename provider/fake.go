@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"vector-ai/constants"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Fake replies with scripted responses in order, repeating the last one, so
+// handlers can be exercised without a model. Streaming callers get the whole
+// response as one chunk. It also embeds, by hashing words.
+type Fake struct {
+	mu        *sync.Mutex
+	responses []string
+	calls     *int
+	Prompts   *[]string // every prompt received, for assertions
+}
+
+// NewFake scripts the given responses, or those in LLM_FAKE_RESPONSES separated
+// by lines of "---" when none are given
+func NewFake(responses ...string) Fake {
+	if len(responses) == 0 {
+		if script := os.Getenv("LLM_FAKE_RESPONSES"); script != "" {
+			responses = strings.Split(script, "\n---\n")
+		} else {
+			responses = []string{"fake response"}
+		}
+	}
+
+	return Fake{mu: &sync.Mutex{}, responses: responses, calls: new(int), Prompts: &[]string{}}
+}
+
+func (f Fake) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	var prompt strings.Builder
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				prompt.WriteString(text.Text)
+			}
+		}
+	}
+
+	f.mu.Lock()
+	*f.Prompts = append(*f.Prompts, prompt.String())
+	response := f.responses[min(*f.calls, len(f.responses)-1)]
+	*f.calls++
+	f.mu.Unlock()
+
+	if opts.StreamingFunc != nil {
+		if err := opts.StreamingFunc(ctx, []byte(response)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: response, StopReason: "stop"}}}, nil
+}
+
+func (f Fake) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+// CreateEmbedding hashes the words of each text into a unit vector of
+// constants.VectorSize, so texts sharing words score close
+func (f Fake) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, constants.VectorSize)
+		vector[0] = 1 // texts without words still have a direction
+
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			hash := fnv.New32a()
+			hash.Write([]byte(word))
+			vector[hash.Sum32()%constants.VectorSize]++
+		}
+
+		var norm float64
+		for _, v := range vector {
+			norm += float64(v) * float64(v)
+		}
+		for j := range vector {
+			vector[j] /= float32(math.Sqrt(norm))
+		}
+		vectors[i] = vector
+	}
+
+	return vectors, nil
+}
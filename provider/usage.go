@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math"
+	"vector-ai/constants"
+	"vector-ai/model"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Price is what a model costs in USD per million tokens
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Prices of the models in Models and the embedder. Models missing here, such as
+// OpenAI-compatible ones, are recorded at no cost.
+var Prices = map[string]Price{
+	constants.LLM:                {Prompt: 5, Completion: 15},
+	"gpt-4o-mini":                {Prompt: 0.15, Completion: 0.6},
+	"gpt-4-turbo":                {Prompt: 10, Completion: 30},
+	"claude-3-5-sonnet-20240620": {Prompt: 3, Completion: 15},
+	"claude-3-haiku-20240307":    {Prompt: 0.25, Completion: 1.25},
+	"claude-3-opus-20240229":     {Prompt: 15, Completion: 75},
+	constants.Embedder:           {Prompt: 0.02},
+}
+
+// Generate is llms.GenerateFromSinglePrompt that also returns the tokens spent. Counts
+// the provider doesn't report, as when streaming, are estimated with the tokenizer.
+func Generate(ctx context.Context, llm llms.Model, prompt string, options ...llms.CallOption) (string, model.TokenUsage, error) {
+	msg := llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: prompt}},
+	}
+
+	resp, err := llm.GenerateContent(ctx, []llms.MessageContent{msg}, options...)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	if len(resp.Choices) < 1 {
+		return "", model.TokenUsage{}, errors.New("empty response from model")
+	}
+	choice := resp.Choices[0]
+
+	// OpenAI and Anthropic name their counts differently
+	usage := model.TokenUsage{
+		PromptTokens:     generationInt(choice.GenerationInfo, "PromptTokens", "InputTokens"),
+		CompletionTokens: generationInt(choice.GenerationInfo, "CompletionTokens", "OutputTokens"),
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = int64(llms.CountTokens(constants.LLM, prompt))
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = int64(llms.CountTokens(constants.LLM, choice.Content))
+	}
+
+	return choice.Content, usage, nil
+}
+
+// CountTokens counts text with the tokenizer of an OpenAI model. Other providers don't
+// publish theirs, so their counts are an estimate of four characters a token.
+func CountTokens(modelName string, text string) int {
+	for _, name := range Models[ProviderOpenAI] {
+		if name == modelName {
+			return llms.CountTokens(modelName, text)
+		}
+	}
+	return len([]rune(text)) / 4
+}
+
+// EmbeddingUsage estimates the tokens the embedder is sent for texts
+func EmbeddingUsage(texts []string) model.TokenUsage {
+	usage := model.TokenUsage{Model: constants.Embedder}
+	for _, text := range texts {
+		usage.EmbeddingTokens += int64(llms.CountTokens(constants.Embedder, text))
+	}
+	return usage
+}
+
+// Cost of usage in millionths of a USD, its prompt and completion at its model's price
+// and its embeddings at the embedder's
+func Cost(usage model.TokenUsage) int64 {
+	chat := Prices[usage.Model]
+	embedding := Prices[constants.Embedder]
+
+	cost := float64(usage.PromptTokens)*chat.Prompt + float64(usage.CompletionTokens)*chat.Completion +
+		float64(usage.EmbeddingTokens)*embedding.Prompt
+	return int64(math.Round(cost))
+}
+
+func generationInt(info map[string]any, keys ...string) int64 {
+	for _, key := range keys {
+		switch value := info[key].(type) {
+		case int:
+			return int64(value)
+		case int64:
+			return value
+		case float64:
+			return int64(value)
+		}
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package qdrant
 
 import (
 	"fmt"
+	"io"
 	"vector-ai/model"
 
 	"github.com/go-errors/errors"
@@ -14,26 +15,53 @@ type Qdr struct {
 	Connection *grpc.ClientConn
 }
 
+// Controls is the vector store. Implementations: Qdr (qdrant over grpc), Memory
+// (in-process brute force, for local development and tests) and pgvector.Pgv
 type Controls interface {
 	GetStatus() (string, error)
 	ListCollections() ([]string, error)
-	GetCollection(string) (*pb.CollectionInfo, error) // GetQdrantCollection
-	CreateCollection(string, uint64) error
+	GetCollection(string) (model.CollectionInfo, error) // GetQdrantCollection
+	CreateCollection(string, uint64, string) error
+	CreatePayloadIndexes(string) error
 	DeleteVectorsByDocumentId(string, string, string) (uint64, error)
 	DeleteVectorsByWorkspaceId(string, string) (uint64, error)
 	ClearCollection(string) (int, error)
 	DeleteCollection(string) error
-	GetPoint(string, string) (*pb.RetrievedPoint, error)
-	GetPointsByUuid(string, []string) ([]*pb.RetrievedPoint, error)
-	GetPointsByIndeces(string, string, []int64) ([]*pb.RetrievedPoint, error)
+	GetPoint(string, string) (model.Chunk, error)
+	GetPointsByUuid(string, []string) ([]model.Chunk, error)
+	GetPointsByIndeces(string, string, []int64) ([]model.Chunk, error)
+	GetPointsByDocuments(string, string, []string) ([]model.Chunk, error) // with vectors
+	SetDocumentTags(string, string, string, []string) error
+
+	// snapshots
+	CreateSnapshot(string) (model.Snapshot, error)
+	ListSnapshots(string) ([]model.Snapshot, error)
+	DownloadSnapshot(string, string) (io.ReadCloser, error)
+	RestoreSnapshot(string, string) error
 
 	// main functions
-	Vss([]float32, string, string, model.VssOptions) (*pb.GroupsResult, error)
-	Query([]float32, string, string) ([]*pb.ScoredPoint, error)
+	Vss([]float32, string, string, model.VssOptions, model.VssFilter) ([]model.Hit, error)
+	OrgVss([]float32, string, []string, model.VssOptions) ([]model.Hit, error)
+	Query([]float32, string, string) ([]model.Hit, error)
 	Upload(string, string, string, [][]float32, []string) (string, error)
 
 	// helper functions
 	GetPointCount(string) (uint32, error) // not in use
+	CountPointsByDocument(string) (map[string]map[string]model.DocumentPoints, error)
+	Scroll(string, string, bool) Iterator
+}
+
+// Iterator pages through stored chunks. Use like bufio.Scanner:
+//
+//	it := store.Scroll(orgId, workspaceId, false)
+//	for it.Next() {
+//		for _, chunk := range it.Page() { ... }
+//	}
+//	err := it.Err()
+type Iterator interface {
+	Next() bool
+	Page() []model.Chunk
+	Err() error
 }
 
 func check(err error) error {
@@ -0,0 +1,499 @@
+package qdrant
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"vector-ai/model"
+	"vector-ai/util"
+)
+
+// ErrSnapshotsUnsupported is returned by the snapshot methods of stores without them
+var ErrSnapshotsUnsupported = errors.New("snapshots are not supported by this vector store")
+
+// Memory is an in-process vector store that searches by brute force. Nothing is
+// persisted, it exists for local development and handler tests without a qdrant server.
+type Memory struct {
+	mu          *sync.RWMutex
+	collections map[string]*memoryCollection
+}
+
+type memoryCollection struct {
+	vectorSize uint64
+	distance   string
+	points     map[string]model.Chunk
+}
+
+func NewMemory() Memory {
+	return Memory{mu: &sync.RWMutex{}, collections: map[string]*memoryCollection{}}
+}
+
+func (m Memory) GetStatus() (string, error) {
+	return "memory", nil
+}
+
+func (m Memory) ListCollections() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := []string{}
+	for name := range m.collections {
+		collections = append(collections, name)
+	}
+	slices.Sort(collections)
+
+	return collections, nil
+}
+
+func (m Memory) GetCollection(orgId string) (model.CollectionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return model.CollectionInfo{}, err
+	}
+
+	return model.CollectionInfo{
+		Status:      "green",
+		PointsCount: uint64(len(collection.points)),
+		VectorSize:  collection.vectorSize,
+		Distance:    collection.distance,
+	}, nil
+}
+
+func (m Memory) CreateCollection(orgId string, vectorSize uint64, distance string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.collections[orgId]; ok {
+		return fmt.Errorf("collection %s already exists", orgId)
+	}
+
+	metric, err := Distance(distance)
+	if err != nil {
+		return err
+	}
+
+	m.collections[orgId] = &memoryCollection{
+		vectorSize: vectorSize,
+		distance:   strings.ToLower(metric.String()),
+		points:     map[string]model.Chunk{},
+	}
+
+	return nil
+}
+
+// nothing to index, every search is a full scan
+func (m Memory) CreatePayloadIndexes(orgId string) error {
+	return nil
+}
+
+func (m Memory) DeleteVectorsByDocumentId(orgId string, workspaceId string, documentId string) (uint64, error) {
+	return m.deleteWhere(orgId, func(chunk model.Chunk) bool {
+		return chunk.WorkspaceID == workspaceId && chunk.DocumentID == documentId
+	})
+}
+
+func (m Memory) DeleteVectorsByWorkspaceId(orgId string, workspaceId string) (uint64, error) {
+	return m.deleteWhere(orgId, func(chunk model.Chunk) bool {
+		return chunk.WorkspaceID == workspaceId
+	})
+}
+
+func (m Memory) ClearCollection(orgId string) (int, error) {
+	deleted, err := m.deleteWhere(orgId, func(chunk model.Chunk) bool { return true })
+	if err == nil && deleted == 0 {
+		return 0, errors.New("collection has no points")
+	}
+
+	return int(deleted), err
+}
+
+func (m Memory) DeleteCollection(orgId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.collection(orgId); err != nil {
+		return err
+	}
+	delete(m.collections, orgId)
+
+	return nil
+}
+
+func (m Memory) GetPoint(orgId string, pointId string) (model.Chunk, error) {
+	chunks, err := m.GetPointsByUuid(orgId, []string{pointId})
+	if err != nil {
+		return model.Chunk{}, err
+	}
+	if len(chunks) == 0 {
+		return model.Chunk{}, fmt.Errorf("point %s not found", pointId)
+	}
+
+	return chunks[0], nil
+}
+
+func (m Memory) GetPointsByUuid(orgId string, pointIds []string) ([]model.Chunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []model.Chunk{}
+	for _, id := range pointIds {
+		if chunk, ok := collection.points[id]; ok {
+			chunks = append(chunks, withoutVector(chunk))
+		}
+	}
+
+	return chunks, nil
+}
+
+func (m Memory) GetPointsByIndeces(orgId string, documentId string, indeces []int64) ([]model.Chunk, error) {
+	chunks, err := m.filter(orgId, func(chunk model.Chunk) bool {
+		return chunk.DocumentID == documentId && slices.Contains(indeces, chunk.Index)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(chunks, func(a model.Chunk, b model.Chunk) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	return chunks, nil
+}
+
+func (m Memory) GetPointsByDocuments(orgId string, workspaceId string, documentIds []string) ([]model.Chunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []model.Chunk{}
+	for _, chunk := range collection.points {
+		if chunk.WorkspaceID == workspaceId && slices.Contains(documentIds, chunk.DocumentID) {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	slices.SortFunc(chunks, func(a model.Chunk, b model.Chunk) int {
+		if c := cmp.Compare(a.DocumentID, b.DocumentID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	return chunks, nil
+}
+
+func (m Memory) SetDocumentTags(orgId string, workspaceId string, documentId string, tagIds []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return err
+	}
+
+	for id, chunk := range collection.points {
+		if chunk.WorkspaceID == workspaceId && chunk.DocumentID == documentId {
+			chunk.Tags = slices.Clone(tagIds)
+			collection.points[id] = chunk
+		}
+	}
+
+	return nil
+}
+
+func (m Memory) CreateSnapshot(orgId string) (model.Snapshot, error) {
+	return model.Snapshot{}, ErrSnapshotsUnsupported
+}
+
+func (m Memory) ListSnapshots(orgId string) ([]model.Snapshot, error) {
+	return nil, ErrSnapshotsUnsupported
+}
+
+func (m Memory) DownloadSnapshot(orgId string, snapshotName string) (io.ReadCloser, error) {
+	return nil, ErrSnapshotsUnsupported
+}
+
+func (m Memory) RestoreSnapshot(orgId string, snapshotName string) error {
+	return ErrSnapshotsUnsupported
+}
+
+func (m Memory) Vss(vector []float32, orgId string, workspaceId string, options model.VssOptions, filter model.VssFilter) ([]model.Hit, error) {
+	return m.search(vector, orgId, options, func(chunk model.Chunk) bool {
+		if chunk.WorkspaceID != workspaceId {
+			return false
+		}
+		if len(filter.DocumentIDs) > 0 && !slices.Contains(filter.DocumentIDs, chunk.DocumentID) {
+			return false
+		}
+		if len(filter.TagIDs) > 0 && !slices.ContainsFunc(chunk.Tags, func(tag string) bool { return slices.Contains(filter.TagIDs, tag) }) {
+			return false
+		}
+		return true
+	})
+}
+
+func (m Memory) OrgVss(vector []float32, orgId string, workspaceIds []string, options model.VssOptions) ([]model.Hit, error) {
+	return m.search(vector, orgId, options, func(chunk model.Chunk) bool {
+		return slices.Contains(workspaceIds, chunk.WorkspaceID)
+	})
+}
+
+func (m Memory) Query(vector []float32, orgId string, workspaceId string) ([]model.Hit, error) {
+	hits, err := m.search(vector, orgId, model.VssOptions{VssDocumentLimit: math.MaxUint32, VssChunkLimit: math.MaxUint32}, func(chunk model.Chunk) bool {
+		return chunk.WorkspaceID == workspaceId
+	})
+
+	return hits[:min(len(hits), 10)], err
+}
+
+// Upload stores chunks under the same deterministic ids as Qdr.Upload and removes
+// the document's points left over from a previous version
+func (m Memory) Upload(orgId string, workspaceId string, documentId string, floats [][]float32, chunks []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return fmt.Sprintf("Could not upsert points: %v", err), err
+	}
+
+	ids := map[string]bool{}
+	uploaded := time.Now().Unix()
+	for i, vector := range floats {
+		chunk := chunks[i]
+		pointId := PointId(documentId, i, chunk)
+		ids[pointId] = true
+
+		collection.points[pointId] = model.Chunk{
+			ID:          pointId,
+			WorkspaceID: workspaceId,
+			DocumentID:  documentId,
+			Index:       int64(i),
+			Text:        chunk,
+			Hash:        ChunkHash(chunk),
+			Tags:        []string{},
+			Vector:      vector,
+			Uploaded:    uploaded,
+		}
+	}
+
+	for id, chunk := range collection.points {
+		if chunk.WorkspaceID == workspaceId && chunk.DocumentID == documentId && !ids[id] {
+			delete(collection.points, id)
+		}
+	}
+
+	return fmt.Sprintf("Upserted %d points \n", len(floats)), nil
+}
+
+func (m Memory) GetPointCount(orgId string) (uint32, error) {
+	info, err := m.GetCollection(orgId)
+	return uint32(info.PointsCount), err
+}
+
+func (m Memory) CountPointsByDocument(orgId string) (map[string]map[string]model.DocumentPoints, error) {
+	chunks, err := m.filter(orgId, func(chunk model.Chunk) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]map[string]model.DocumentPoints{}
+	countChunks(counts, chunks)
+
+	return counts, nil
+}
+
+func (m Memory) Scroll(orgId string, workspaceId string, withVectors bool) Iterator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	it := &memoryIterator{}
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		it.err = err
+		return it
+	}
+
+	for _, chunk := range collection.points {
+		if workspaceId != "" && chunk.WorkspaceID != workspaceId {
+			continue
+		}
+		if !withVectors {
+			chunk = withoutVector(chunk)
+		}
+		it.chunks = append(it.chunks, chunk)
+	}
+
+	slices.SortFunc(it.chunks, func(a model.Chunk, b model.Chunk) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return it
+}
+
+// memoryIterator pages through a copy of the matching chunks taken when scrolling started
+type memoryIterator struct {
+	chunks []model.Chunk
+	page   []model.Chunk
+	err    error
+}
+
+func (it *memoryIterator) Next() bool {
+	if it.err != nil || len(it.chunks) == 0 {
+		return false
+	}
+
+	size := min(len(it.chunks), int(ScrollPageSize))
+	it.page, it.chunks = it.chunks[:size], it.chunks[size:]
+
+	return true
+}
+
+func (it *memoryIterator) Page() []model.Chunk {
+	return it.page
+}
+
+func (it *memoryIterator) Err() error {
+	return it.err
+}
+
+// callers hold the lock
+func (m Memory) collection(orgId string) (*memoryCollection, error) {
+	collection, ok := m.collections[orgId]
+	if !ok {
+		return nil, fmt.Errorf("collection %s not found", orgId)
+	}
+
+	return collection, nil
+}
+
+// filter returns copies of the matching chunks, without vectors
+func (m Memory) filter(orgId string, match func(model.Chunk) bool) ([]model.Chunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []model.Chunk{}
+	for _, chunk := range collection.points {
+		if match(chunk) {
+			chunks = append(chunks, withoutVector(chunk))
+		}
+	}
+
+	return chunks, nil
+}
+
+func (m Memory) deleteWhere(orgId string, match func(model.Chunk) bool) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted uint64
+	for id, chunk := range collection.points {
+		if match(chunk) {
+			delete(collection.points, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// search scores every matching chunk and keeps the best VssChunkLimit chunks of the
+// best VssDocumentLimit documents, like qdrant's grouped search
+func (m Memory) search(vector []float32, orgId string, options model.VssOptions, match func(model.Chunk) bool) ([]model.Hit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, err := m.collection(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := float32(options.VssScoreThreshold) / 1000
+
+	hits := []model.Hit{}
+	for _, chunk := range collection.points {
+		if !match(chunk) {
+			continue
+		}
+
+		score := similarity(collection.distance, vector, chunk.Vector)
+		if options.VssScoreThreshold > 0 && score < threshold {
+			continue
+		}
+
+		hits = append(hits, model.Hit{
+			ID:          chunk.ID,
+			WorkspaceID: chunk.WorkspaceID,
+			DocumentID:  chunk.DocumentID,
+			Index:       chunk.Index,
+			Value:       chunk.Text,
+			Score:       score,
+			Vector:      chunk.Vector,
+		})
+	}
+
+	slices.SortStableFunc(hits, func(a model.Hit, b model.Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return util.LimitPerDocument(hits, options.VssDocumentLimit, options.VssChunkLimit), nil
+}
+
+// similarity scores higher for closer vectors. Euclidean distance is mapped to
+// 1/(1+d) so every metric sorts the same way and score thresholds stay meaningful.
+func similarity(distance string, a []float32, b []float32) float32 {
+	switch distance {
+	case "cosine":
+		return util.CosineSimilarity(a, b)
+	case "euclid":
+		var sum float64
+		for i := range min(len(a), len(b)) {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return float32(1 / (1 + math.Sqrt(sum)))
+	default:
+		var dot float64
+		for i := range min(len(a), len(b)) {
+			dot += float64(a[i]) * float64(b[i])
+		}
+		return float32(dot)
+	}
+}
+
+func withoutVector(chunk model.Chunk) model.Chunk {
+	chunk.Vector = nil
+	return chunk
+}
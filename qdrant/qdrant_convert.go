@@ -0,0 +1,88 @@
+package qdrant
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"vector-ai/model"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// conversions from protobuf points to the domain types Controls returns
+
+func toChunk(id *pb.PointId, payload map[string]*pb.Value, vectors *pb.Vectors) model.Chunk {
+	tags := []string{}
+	for _, tag := range payload["tags"].GetListValue().GetValues() {
+		tags = append(tags, tag.GetStringValue())
+	}
+
+	return model.Chunk{
+		ID:          id.GetUuid(),
+		WorkspaceID: payload["workspaceId"].GetStringValue(),
+		DocumentID:  payload["documentId"].GetStringValue(),
+		Index:       payload["index"].GetIntegerValue(),
+		Text:        payload["chunk"].GetStringValue(),
+		Hash:        payload["hash"].GetStringValue(),
+		Tags:        tags,
+		Vector:      vectors.GetVector().GetData(),
+		Uploaded:    payload["uploaded"].GetIntegerValue(),
+	}
+}
+
+func toChunks(points []*pb.RetrievedPoint) []model.Chunk {
+	chunks := []model.Chunk{}
+	for _, point := range points {
+		chunks = append(chunks, toChunk(point.GetId(), point.GetPayload(), point.GetVectors()))
+	}
+	return chunks
+}
+
+// toHit converts a search result, scoring it like the other stores: higher is better
+func toHit(point *pb.ScoredPoint, metric pb.Distance) model.Hit {
+	chunk := toChunk(point.GetId(), point.GetPayload(), point.GetVectors())
+
+	// euclid results carry the raw distance, lower being better
+	score := point.GetScore()
+	if metric == pb.Distance_Euclid {
+		score = 1 / (1 + score)
+	}
+
+	return model.Hit{
+		ID:          chunk.ID,
+		WorkspaceID: chunk.WorkspaceID,
+		DocumentID:  chunk.DocumentID,
+		Index:       chunk.Index,
+		Value:       chunk.Text,
+		Score:       score,
+		Vector:      chunk.Vector,
+	}
+}
+
+// flattens grouped search results into hits, best score first
+func groupHits(groupsResult *pb.GroupsResult, metric pb.Distance) []model.Hit {
+	hits := []model.Hit{}
+
+	for _, pg := range groupsResult.GetGroups() {
+		for _, point := range pg.GetHits() {
+			hits = append(hits, toHit(point, metric))
+		}
+	}
+
+	slices.SortStableFunc(hits, func(a model.Hit, b model.Hit) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return hits
+}
+
+func toCollectionInfo(info *pb.CollectionInfo) model.CollectionInfo {
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+
+	return model.CollectionInfo{
+		Status:      strings.ToLower(info.GetStatus().String()),
+		PointsCount: info.GetPointsCount(),
+		VectorSize:  params.GetSize(),
+		Distance:    strings.ToLower(params.GetDistance().String()),
+	}
+}
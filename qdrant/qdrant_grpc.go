@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"slices"
+	"strings"
+	"time"
 
+	"vector-ai/model"
 	"vector-ai/util"
 
 	pb "github.com/qdrant/go-client/qdrant"
@@ -49,7 +52,7 @@ func (qdr Qdr) ListCollections() ([]string, error) {
 	return collections, err
 }
 
-func (qdr Qdr) GetCollection(qId string) (*pb.CollectionInfo, error) {
+func (qdr Qdr) GetCollection(qId string) (model.CollectionInfo, error) {
 	ctx, cancel := util.GetContext()
 	defer cancel()
 
@@ -57,28 +60,46 @@ func (qdr Qdr) GetCollection(qId string) (*pb.CollectionInfo, error) {
 		CollectionName: qId,
 	})
 	if err != nil {
-		return nil, err
+		return model.CollectionInfo{}, err
 	}
 
-	info := r.GetResult()
+	return toCollectionInfo(r.GetResult()), nil
+}
 
-	return info, err
+// Distance maps a metric name (cosine, dot, euclid) to its qdrant enum
+func Distance(name string) (pb.Distance, error) {
+	switch strings.ToLower(name) {
+	case "cosine":
+		return pb.Distance_Cosine, nil
+	case "euclid", "euclidean":
+		return pb.Distance_Euclid, nil
+	case "dot":
+		return pb.Distance_Dot, nil
+	default:
+		return pb.Distance_UnknownDistance, fmt.Errorf("unknown distance %q, use cosine, dot or euclid", name)
+	}
 }
 
 // used by upload_drive and upload_manual
-func (qdr Qdr) CreateCollection(orgId string, vectorSize uint64) error {
+func (qdr Qdr) CreateCollection(orgId string, vectorSize uint64, distance string) error {
 
 	ctx, cancel := util.GetContext()
 	defer cancel()
 
+	metric, err := Distance(distance)
+	if err != nil {
+		return err
+	}
+	distances.Delete(orgId)
+
 	// Create new collection
 	var defaultSegmentNumber uint64 = 2
-	_, err := qdr.Driver.Create(ctx, &pb.CreateCollection{
+	_, err = qdr.Driver.Create(ctx, &pb.CreateCollection{
 		CollectionName: orgId,
 		VectorsConfig: &pb.VectorsConfig{Config: &pb.VectorsConfig_Params{
 			Params: &pb.VectorParams{
 				Size:     vectorSize,
-				Distance: pb.Distance_Dot,
+				Distance: metric,
 			},
 		}},
 		OptimizersConfig: &pb.OptimizersConfigDiff{
@@ -87,12 +108,45 @@ func (qdr Qdr) CreateCollection(orgId string, vectorSize uint64) error {
 	})
 
 	if err != nil {
-		log.Fatalf("\nCould not create collection: %v", err)
-	} else {
-		log.Println("\nCollection", orgId, "created")
+		log.Println("\nCould not create collection:", err)
+		return err
+	}
+
+	log.Println("\nCollection", orgId, "created with", metric, "distance")
+
+	return qdr.CreatePayloadIndexes(orgId)
+}
+
+// payload fields every filter and delete matches on
+var payloadIndexes = map[string]pb.FieldType{
+	"workspaceId": pb.FieldType_FieldTypeKeyword,
+	"documentId":  pb.FieldType_FieldTypeKeyword,
+	"tags":        pb.FieldType_FieldTypeKeyword,
+	"index":       pb.FieldType_FieldTypeInteger,
+}
+
+// CreatePayloadIndexes indexes the filtered payload fields, safe to re-run on existing collections
+func (qdr Qdr) CreatePayloadIndexes(orgId string) error {
+	ctx, cancel := util.GetContextWithDuration(30)
+	defer cancel()
+
+	pointsClient := pb.NewPointsClient(qdr.Connection)
+
+	waitIndex := true
+	for fieldName, fieldType := range payloadIndexes {
+		_, err := pointsClient.CreateFieldIndex(ctx, &pb.CreateFieldIndexCollection{
+			CollectionName: orgId,
+			Wait:           &waitIndex,
+			FieldName:      fieldName,
+			FieldType:      fieldType.Enum(),
+		})
+		if err != nil {
+			log.Println("Could not create", fieldName, "index:", err)
+			return err
+		}
 	}
 
-	return fmt.Errorf("create error")
+	return nil
 }
 
 func (qdr Qdr) DeleteVectorsByWorkspaceId(orgId string, workspaceId string) (uint64, error) {
@@ -112,8 +166,8 @@ func (qdr Qdr) DeleteVectorsByWorkspaceId(orgId string, workspaceId string) (uin
 						Field: &pb.FieldCondition{
 							Key: "workspaceId",
 							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: workspaceId,
+								MatchValue: &pb.Match_Keyword{
+									Keyword: workspaceId,
 								},
 							},
 						},
@@ -141,8 +195,8 @@ func (qdr Qdr) DeleteVectorsByWorkspaceId(orgId string, workspaceId string) (uin
 								Field: &pb.FieldCondition{
 									Key: "workspaceId",
 									Match: &pb.Match{
-										MatchValue: &pb.Match_Text{
-											Text: workspaceId,
+										MatchValue: &pb.Match_Keyword{
+											Keyword: workspaceId,
 										},
 									},
 								},
@@ -180,8 +234,8 @@ func (qdr Qdr) DeleteVectorsByDocumentId(orgId string, workspaceId string, docum
 						Field: &pb.FieldCondition{
 							Key: "workspaceId",
 							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: workspaceId,
+								MatchValue: &pb.Match_Keyword{
+									Keyword: workspaceId,
 								},
 							},
 						},
@@ -192,8 +246,8 @@ func (qdr Qdr) DeleteVectorsByDocumentId(orgId string, workspaceId string, docum
 						Field: &pb.FieldCondition{
 							Key: "documentId",
 							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: documentId,
+								MatchValue: &pb.Match_Keyword{
+									Keyword: documentId,
 								},
 							},
 						},
@@ -221,8 +275,8 @@ func (qdr Qdr) DeleteVectorsByDocumentId(orgId string, workspaceId string, docum
 								Field: &pb.FieldCondition{
 									Key: "workspaceId",
 									Match: &pb.Match{
-										MatchValue: &pb.Match_Text{
-											Text: workspaceId,
+										MatchValue: &pb.Match_Keyword{
+											Keyword: workspaceId,
 										},
 									},
 								},
@@ -233,8 +287,8 @@ func (qdr Qdr) DeleteVectorsByDocumentId(orgId string, workspaceId string, docum
 								Field: &pb.FieldCondition{
 									Key: "documentId",
 									Match: &pb.Match{
-										MatchValue: &pb.Match_Text{
-											Text: documentId,
+										MatchValue: &pb.Match_Keyword{
+											Keyword: documentId,
 										},
 									},
 								},
@@ -261,63 +315,44 @@ func (qdr Qdr) ClearCollection(orgId string) (int, error) {
 	ctx, cancel := util.GetContextWithDuration(30)
 	defer cancel()
 
-	// Get collection info -> number of points in collection
-	r, err := qdr.Driver.Get(ctx, &pb.GetCollectionInfoRequest{
-		CollectionName: orgId,
-	})
-	check(err)
-
-	info := r.GetResult()
-	pointCount := uint32(info.PointsCount)
-
 	pointsClient := pb.NewPointsClient(qdr.Connection)
 
-	if pointCount < 1 {
-		return 0, errors.New("collection has no points")
-	}
-
-	// scroll collection to Get all ids
-	scroll, err := pointsClient.Scroll(ctx, &pb.ScrollPoints{
+	exact := true
+	countResponse, err := pointsClient.Count(ctx, &pb.CountPoints{
 		CollectionName: orgId,
-		Limit:          &pointCount,
+		Exact:          &exact,
 	})
-	check(err)
-
-	scrollResult := scroll.GetResult()
-
-	// create slice of ids from result
-	ids := []*pb.PointId{}
-
-	for _, nb := range scrollResult {
-		vim := nb.GetPayload()
-		for index := range vim {
-			fmt.Println(index)
-		}
+	if err != nil {
+		return 0, check(err)
+	}
 
-		id := nb.GetId()
-		ids = append(ids, id)
+	pointCount := int(countResponse.GetResult().GetCount())
+	if pointCount < 1 {
+		return 0, errors.New("collection has no points")
 	}
 
 	waitDelete := true
 
-	// Delete points using list of ids
+	// an empty filter matches every point, no need to enumerate ids
 	response, err := pointsClient.Delete(ctx, &pb.DeletePoints{
 		CollectionName: orgId,
 		Wait:           &waitDelete,
-		Points:         &pb.PointsSelector{PointsSelectorOneOf: &pb.PointsSelector_Points{Points: &pb.PointsIdsList{Ids: ids}}},
+		Points:         &pb.PointsSelector{PointsSelectorOneOf: &pb.PointsSelector_Filter{Filter: &pb.Filter{}}},
 	})
 
 	if err != nil {
-		log.Fatalf("Could not clear points: %v", err)
-	} else {
-		log.Println(response.GetResult())
-		log.Println("Deleted", len(ids), "points")
+		log.Println("Could not clear points:", err)
+		return 0, err
 	}
 
-	return len(ids), err
+	log.Println(response.GetResult())
+	log.Println("Deleted", pointCount, "points")
+
+	return pointCount, nil
 }
 
 func (qdr Qdr) DeleteCollection(collectionId string) error {
+	distances.Delete(collectionId)
 
 	ctx, cancel := util.GetContext()
 	defer cancel()
@@ -336,7 +371,7 @@ func (qdr Qdr) DeleteCollection(collectionId string) error {
 	return err
 }
 
-func (qdr Qdr) GetPoint(orgId string, pointId string) (*pb.RetrievedPoint, error) {
+func (qdr Qdr) GetPoint(orgId string, pointId string) (model.Chunk, error) {
 	ctx, cancel := util.GetContext()
 	defer cancel()
 
@@ -349,12 +384,19 @@ func (qdr Qdr) GetPoint(orgId string, pointId string) (*pb.RetrievedPoint, error
 		},
 		WithPayload: &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
 	})
-	check(err)
+	if check(err) != nil {
+		return model.Chunk{}, err
+	}
 
-	return GetResponse.GetResult()[0], err
+	points := GetResponse.GetResult()
+	if len(points) == 0 {
+		return model.Chunk{}, fmt.Errorf("point %s not found", pointId)
+	}
+
+	return toChunks(points)[0], nil
 }
 
-func (qdr Qdr) GetPointsByUuid(orgId string, pointIds []string) ([]*pb.RetrievedPoint, error) {
+func (qdr Qdr) GetPointsByUuid(orgId string, pointIds []string) ([]model.Chunk, error) {
 	pointsClient := pb.NewPointsClient(qdr.Connection)
 	ids := []*pb.PointId{}
 
@@ -375,10 +417,10 @@ func (qdr Qdr) GetPointsByUuid(orgId string, pointIds []string) ([]*pb.Retrieved
 
 	check(err)
 
-	return response.GetResult(), err
+	return toChunks(response.GetResult()), err
 }
 
-func (qdr Qdr) GetPointsByIndeces(orgId string, documentId string, indeces []int64) ([]*pb.RetrievedPoint, error) {
+func (qdr Qdr) GetPointsByIndeces(orgId string, documentId string, indeces []int64) ([]model.Chunk, error) {
 	ctx, cancel := util.GetContext()
 	defer cancel()
 
@@ -394,8 +436,8 @@ func (qdr Qdr) GetPointsByIndeces(orgId string, documentId string, indeces []int
 						Field: &pb.FieldCondition{
 							Key: "documentId",
 							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: documentId,
+								MatchValue: &pb.Match_Keyword{
+									Keyword: documentId,
 								},
 							},
 						},
@@ -422,17 +464,80 @@ func (qdr Qdr) GetPointsByIndeces(orgId string, documentId string, indeces []int
 		WithPayload: &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
 	})
 
-	searchResults := searchResponse.GetResult()
+	chunks := toChunks(searchResponse.GetResult())
 
-	slices.SortFunc(searchResults,
-		func(a *pb.RetrievedPoint, b *pb.RetrievedPoint) int {
-			return cmp.Compare(a.GetPayload()["index"].GetIntegerValue(), b.GetPayload()["index"].GetIntegerValue())
+	slices.SortFunc(chunks,
+		func(a model.Chunk, b model.Chunk) int {
+			return cmp.Compare(a.Index, b.Index)
 		})
 
-	return searchResults, err
+	return chunks, err
+}
+
+// GetPointsByDocuments returns every point of the given documents in the workspace
+// with vectors, ordered by document and index
+func (qdr Qdr) GetPointsByDocuments(orgId string, workspaceId string, documentIds []string) ([]model.Chunk, error) {
+	filter := workspaceFilter(workspaceId)
+	filter.Must = append(filter.Must,
+		&pb.Condition{
+			ConditionOneOf: &pb.Condition_Field{
+				Field: &pb.FieldCondition{
+					Key: "documentId",
+					Match: &pb.Match{
+						MatchValue: &pb.Match_Keywords{
+							Keywords: &pb.RepeatedStrings{Strings: documentIds},
+						},
+					},
+				},
+			},
+		},
+	)
+
+	chunks := []model.Chunk{}
+	scroller := qdr.scroll(orgId, filter, nil, true)
+	for scroller.Next() {
+		chunks = append(chunks, scroller.Page()...)
+	}
+
+	slices.SortFunc(chunks, func(a model.Chunk, b model.Chunk) int {
+		if c := cmp.Compare(a.DocumentID, b.DocumentID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	return chunks, scroller.Err()
+}
+
+// CountPointsByDocument scrolls the whole collection and counts points per
+// workspaceId, then documentId
+func (qdr Qdr) CountPointsByDocument(orgId string) (map[string]map[string]model.DocumentPoints, error) {
+	counts := map[string]map[string]model.DocumentPoints{}
+
+	scroller := qdr.scroll(orgId, nil, []string{"workspaceId", "documentId", "uploaded"}, false)
+	for scroller.Next() {
+		countChunks(counts, scroller.Page())
+	}
+
+	return counts, scroller.Err()
+}
+
+// countChunks adds chunks to the counts of their documents
+func countChunks(counts map[string]map[string]model.DocumentPoints, chunks []model.Chunk) {
+	for _, chunk := range chunks {
+		if _, ok := counts[chunk.WorkspaceID]; !ok {
+			counts[chunk.WorkspaceID] = map[string]model.DocumentPoints{}
+		}
+
+		document := counts[chunk.WorkspaceID][chunk.DocumentID]
+		document.Points++
+		if uploaded := time.Unix(chunk.Uploaded, 0); uploaded.After(document.Uploaded) {
+			document.Uploaded = uploaded
+		}
+		counts[chunk.WorkspaceID][chunk.DocumentID] = document
+	}
 }
 
-// currently not in use
 func (qdr Qdr) GetPointCount(collectionId string) (uint32, error) {
 	ctx, cancel := util.GetContextWithDuration(30)
 	defer cancel()
@@ -0,0 +1,66 @@
+package qdrant
+
+import (
+	"vector-ai/util"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// overwrites the tags payload on every point belonging to a document
+func (qdr Qdr) SetDocumentTags(orgId string, workspaceId string, documentId string, tagIds []string) error {
+	ctx, cancel := util.GetContextWithDuration(30)
+	defer cancel()
+
+	pointsClient := pb.NewPointsClient(qdr.Connection)
+
+	tags := []*pb.Value{}
+	for _, tagId := range tagIds {
+		tags = append(tags, &pb.Value{Kind: &pb.Value_StringValue{StringValue: tagId}})
+	}
+
+	waitSet := true
+
+	_, err := pointsClient.SetPayload(ctx, &pb.SetPayloadPoints{
+		CollectionName: orgId,
+		Wait:           &waitSet,
+		Payload: map[string]*pb.Value{
+			"tags": {
+				Kind: &pb.Value_ListValue{ListValue: &pb.ListValue{Values: tags}},
+			},
+		},
+		PointsSelector: &pb.PointsSelector{
+			PointsSelectorOneOf: &pb.PointsSelector_Filter{
+				Filter: &pb.Filter{
+					Must: []*pb.Condition{
+						{
+							ConditionOneOf: &pb.Condition_Field{
+								Field: &pb.FieldCondition{
+									Key: "workspaceId",
+									Match: &pb.Match{
+										MatchValue: &pb.Match_Keyword{
+											Keyword: workspaceId,
+										},
+									},
+								},
+							},
+						},
+						{
+							ConditionOneOf: &pb.Condition_Field{
+								Field: &pb.FieldCondition{
+									Key: "documentId",
+									Match: &pb.Match{
+										MatchValue: &pb.Match_Keyword{
+											Keyword: documentId,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	return check(err)
+}
@@ -1,18 +1,24 @@
 package qdrant
 
 import (
+	"vector-ai/model"
 	"vector-ai/util"
 
 	pb "github.com/qdrant/go-client/qdrant"
 )
 
-func (qdr Qdr) Query(vector []float32, orgId string, workspaceId string) ([]*pb.ScoredPoint, error) {
+func (qdr Qdr) Query(vector []float32, orgId string, workspaceId string) ([]model.Hit, error) {
 
 	ctx, cancel := util.GetContext()
 	defer cancel()
 
 	pointsClient := pb.NewPointsClient(qdr.Connection)
 
+	metric, err := qdr.distance(orgId)
+	if err != nil {
+		return nil, err
+	}
+
 	// Unfiltered search
 	unfilteredSearchResult, err := pointsClient.Search(ctx, &pb.SearchPoints{
 		CollectionName: orgId,
@@ -28,8 +34,8 @@ func (qdr Qdr) Query(vector []float32, orgId string, workspaceId string) ([]*pb.
 						Field: &pb.FieldCondition{
 							Key: "workspaceId",
 							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: workspaceId,
+								MatchValue: &pb.Match_Keyword{
+									Keyword: workspaceId,
 								},
 							},
 						},
@@ -39,5 +45,10 @@ func (qdr Qdr) Query(vector []float32, orgId string, workspaceId string) ([]*pb.
 		},
 	})
 
-	return unfilteredSearchResult.GetResult(), err
+	hits := []model.Hit{}
+	for _, point := range unfilteredSearchResult.GetResult() {
+		hits = append(hits, toHit(point, metric))
+	}
+
+	return hits, err
 }
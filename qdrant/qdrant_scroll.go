@@ -0,0 +1,117 @@
+package qdrant
+
+import (
+	"vector-ai/model"
+	"vector-ai/util"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// points fetched per scroll request
+const ScrollPageSize uint32 = 1000
+
+// Scroller pages through the points of a collection, one request per page, so
+// large orgs are never loaded in a single response
+type Scroller struct {
+	client      pb.PointsClient
+	collection  string
+	filter      *pb.Filter
+	withPayload *pb.WithPayloadSelector
+	withVectors bool
+	offset      *pb.PointId
+	page        []model.Chunk
+	done        bool
+	err         error
+}
+
+// Scroll iterates every point of a workspace, or of the whole org if workspaceId is empty
+func (qdr Qdr) Scroll(orgId string, workspaceId string, withVectors bool) Iterator {
+	var filter *pb.Filter
+	if workspaceId != "" {
+		filter = workspaceFilter(workspaceId)
+	}
+
+	return qdr.scroll(orgId, filter, nil, withVectors)
+}
+
+// scroll returns a Scroller over points matching filter (nil for all points), with
+// only the given payload fields (nil for the whole payload) and optionally vectors
+func (qdr Qdr) scroll(orgId string, filter *pb.Filter, fields []string, withVectors bool) *Scroller {
+	withPayload := &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}}
+	if fields != nil {
+		withPayload = &pb.WithPayloadSelector{
+			SelectorOptions: &pb.WithPayloadSelector_Include{
+				Include: &pb.PayloadIncludeSelector{Fields: fields},
+			},
+		}
+	}
+
+	return &Scroller{
+		client:      pb.NewPointsClient(qdr.Connection),
+		collection:  orgId,
+		filter:      filter,
+		withPayload: withPayload,
+		withVectors: withVectors,
+	}
+}
+
+// Next fetches the next page, returning false when the collection is exhausted or on error
+func (s *Scroller) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+
+	ctx, cancel := util.GetContextWithDuration(30)
+	defer cancel()
+
+	pageSize := ScrollPageSize
+	scroll, err := s.client.Scroll(ctx, &pb.ScrollPoints{
+		CollectionName: s.collection,
+		Filter:         s.filter,
+		Offset:         s.offset,
+		Limit:          &pageSize,
+		WithPayload:    s.withPayload,
+		WithVectors: &pb.WithVectorsSelector{
+			SelectorOptions: &pb.WithVectorsSelector_Enable{Enable: s.withVectors},
+		},
+	})
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	s.page = toChunks(scroll.GetResult())
+	s.offset = scroll.GetNextPageOffset()
+	s.done = s.offset == nil
+
+	return len(s.page) > 0
+}
+
+// Page is the current page of points
+func (s *Scroller) Page() []model.Chunk {
+	return s.page
+}
+
+func (s *Scroller) Err() error {
+	return s.err
+}
+
+// workspaceFilter matches every point of a workspace
+func workspaceFilter(workspaceId string) *pb.Filter {
+	return &pb.Filter{
+		Must: []*pb.Condition{
+			{
+				ConditionOneOf: &pb.Condition_Field{
+					Field: &pb.FieldCondition{
+						Key: "workspaceId",
+						Match: &pb.Match{
+							MatchValue: &pb.Match_Keyword{
+								Keyword: workspaceId,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package qdrant
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"vector-ai/model"
+	"vector-ai/util"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// the grpc api can only create, list and delete snapshots, so downloads and
+// restores go through the qdrant http api at QDRANT_HTTP (e.g. https://host:6333)
+
+func (qdr Qdr) CreateSnapshot(orgId string) (model.Snapshot, error) {
+	ctx, cancel := util.GetContextWithDuration(300)
+	defer cancel()
+
+	snapshotsClient := pb.NewSnapshotsClient(qdr.Connection)
+
+	r, err := snapshotsClient.Create(ctx, &pb.CreateSnapshotRequest{CollectionName: orgId})
+	if err != nil {
+		return model.Snapshot{}, check(err)
+	}
+
+	return snapshot(r.GetSnapshotDescription()), nil
+}
+
+func (qdr Qdr) ListSnapshots(orgId string) ([]model.Snapshot, error) {
+	ctx, cancel := util.GetContext()
+	defer cancel()
+
+	snapshotsClient := pb.NewSnapshotsClient(qdr.Connection)
+
+	r, err := snapshotsClient.List(ctx, &pb.ListSnapshotsRequest{CollectionName: orgId})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []model.Snapshot{}
+	for _, description := range r.GetSnapshotDescriptions() {
+		snapshots = append(snapshots, snapshot(description))
+	}
+
+	return snapshots, nil
+}
+
+// DownloadSnapshot streams a snapshot file, the caller closes it
+func (qdr Qdr) DownloadSnapshot(orgId string, snapshotName string) (io.ReadCloser, error) {
+	res, err := restRequest(http.MethodGet, "/collections/"+url.PathEscape(orgId)+"/snapshots/"+url.PathEscape(snapshotName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// RestoreSnapshot recovers the org collection from one of its snapshots on the qdrant
+// node, recreating the collection if it was deleted
+func (qdr Qdr) RestoreSnapshot(orgId string, snapshotName string) error {
+	snapshotsPath := os.Getenv("QDRANT_SNAPSHOTS_PATH")
+	if snapshotsPath == "" {
+		snapshotsPath = "/qdrant/snapshots"
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"location": "file://" + path.Join(snapshotsPath, orgId, snapshotName),
+		"priority": "snapshot",
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := restRequest(http.MethodPut, "/collections/"+url.PathEscape(orgId)+"/snapshots/recover?wait=true", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return nil
+}
+
+func restRequest(method string, endpoint string, body io.Reader) (*http.Response, error) {
+	base := os.Getenv("QDRANT_HTTP")
+	if base == "" {
+		return nil, errors.New("QDRANT_HTTP is not set")
+	}
+
+	req, err := http.NewRequest(method, base+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey := os.Getenv("QDRANT_API_KEY"); apiKey != "" {
+		req.Header.Set("api-key", apiKey)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, check(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		message, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("qdrant responded %s: %s", res.Status, message)
+	}
+
+	return res, nil
+}
+
+func snapshot(description *pb.SnapshotDescription) model.Snapshot {
+	return model.Snapshot{
+		Name:         description.GetName(),
+		CreationTime: description.GetCreationTime().AsTime(),
+		Size:         description.GetSize(),
+	}
+}
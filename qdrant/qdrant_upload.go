@@ -1,7 +1,10 @@
 package qdrant
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"time"
 
 	"vector-ai/constants"
 	"vector-ai/util"
@@ -10,15 +13,32 @@ import (
 	pb "github.com/qdrant/go-client/qdrant"
 )
 
+// namespace for deterministic point ids, never change this
+var pointNamespace = uuid.MustParse("6f1c0a0e-3b9e-4d5c-9a57-2f8e4b1d7c3a")
+
+// ChunkHash returns the hex sha256 of a chunk's text
+func ChunkHash(chunk string) string {
+	sum := sha256.Sum256([]byte(chunk))
+	return hex.EncodeToString(sum[:])
+}
+
+// PointId derives a UUIDv5 from documentId, chunk index and content hash,
+// so an unchanged re-index produces the same ids and upserts overwrite
+func PointId(documentId string, index int, chunk string) string {
+	name := fmt.Sprintf("%s:%d:%s", documentId, index, ChunkHash(chunk))
+	return uuid.NewSHA1(pointNamespace, []byte(name)).String()
+}
+
 func (qdr Qdr) Upload(orgId string, workspaceId string, documentId string, floats [][]float32, chunks []string) (string, error) {
 
 	points := []*pb.PointStruct{}
+	ids := []*pb.PointId{}
+	uploaded := time.Now().Unix()
 
 	// Upload points
 	for i, vector := range floats {
 		chunk := chunks[i]
-		uuid := uuid.New()
-		pointId := uuid.String()
+		pointId := PointId(documentId, i, chunk)
 		//fmt.Println(pointId, chunk)
 
 		point := pb.PointStruct{
@@ -42,13 +62,20 @@ func (qdr Qdr) Upload(orgId string, workspaceId string, documentId string, float
 				"index": {
 					Kind: &pb.Value_IntegerValue{IntegerValue: int64(i)},
 				},
+				"hash": {
+					Kind: &pb.Value_StringValue{StringValue: ChunkHash(chunk)},
+				},
 				"embedder": {
 					Kind: &pb.Value_StringValue{StringValue: constants.Embedder},
 				},
+				"uploaded": {
+					Kind: &pb.Value_IntegerValue{IntegerValue: uploaded},
+				},
 			},
 		}
 
 		points = append(points, &point)
+		ids = append(ids, point.Id)
 	}
 
 	// Create points grpc client
@@ -69,6 +96,55 @@ func (qdr Qdr) Upload(orgId string, workspaceId string, documentId string, float
 	// updateStatus := updateResult.GetStatus()
 	// status := updateStatus.String() // completely useless
 
+	// remove points left over from a previous version of the document
+	if err == nil {
+		_, err = pointsClient.Delete(ctx, &pb.DeletePoints{
+			CollectionName: orgId,
+			Wait:           &waitUpsert,
+			Points: &pb.PointsSelector{
+				PointsSelectorOneOf: &pb.PointsSelector_Filter{
+					Filter: &pb.Filter{
+						Must: []*pb.Condition{
+							{
+								ConditionOneOf: &pb.Condition_Field{
+									Field: &pb.FieldCondition{
+										Key: "workspaceId",
+										Match: &pb.Match{
+											MatchValue: &pb.Match_Keyword{
+												Keyword: workspaceId,
+											},
+										},
+									},
+								},
+							},
+							{
+								ConditionOneOf: &pb.Condition_Field{
+									Field: &pb.FieldCondition{
+										Key: "documentId",
+										Match: &pb.Match{
+											MatchValue: &pb.Match_Keyword{
+												Keyword: documentId,
+											},
+										},
+									},
+								},
+							},
+						},
+						MustNot: []*pb.Condition{
+							{
+								ConditionOneOf: &pb.Condition_HasId{
+									HasId: &pb.HasIdCondition{
+										HasId: ids,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
 	if err == nil {
 		upsert := fmt.Sprintf("Upserted %d points \n", len(points))
 		return upsert, err
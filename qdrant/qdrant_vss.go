@@ -1,19 +1,62 @@
 package qdrant
 
 import (
+	"sync"
+	"vector-ai/constants"
 	"vector-ai/model"
 	"vector-ai/util"
 
 	pb "github.com/qdrant/go-client/qdrant"
 )
 
-// TODO: receive documentIds
-func (qdr Qdr) Vss(vector []float32, orgId string, workspaceId string, options model.VssOptions) (*pb.GroupsResult, error) {
+func (qdr Qdr) Vss(vector []float32, orgId string, workspaceId string, options model.VssOptions, filter model.VssFilter) ([]model.Hit, error) {
+	return qdr.searchGroups(vector, orgId, options, vssFilter(workspaceId, filter))
+}
+
+// OrgVss searches the whole org collection, limited to the given workspaces
+func (qdr Qdr) OrgVss(vector []float32, orgId string, workspaceIds []string, options model.VssOptions) ([]model.Hit, error) {
+	filter := &pb.Filter{
+		Must: []*pb.Condition{
+			{
+				ConditionOneOf: &pb.Condition_Field{
+					Field: &pb.FieldCondition{
+						Key: "workspaceId",
+						Match: &pb.Match{
+							MatchValue: &pb.Match_Keywords{
+								Keywords: &pb.RepeatedStrings{Strings: workspaceIds},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return qdr.searchGroups(vector, orgId, options, filter)
+}
+
+// searchGroups returns the best chunks of the best documents, flattened best score first
+func (qdr Qdr) searchGroups(vector []float32, orgId string, options model.VssOptions, filter *pb.Filter) ([]model.Hit, error) {
 	ctx, cancel := util.GetContextWithDuration(30)
 	defer cancel()
 
 	pointsClient := pb.NewPointsClient(qdr.Connection)
 
+	metric, err := qdr.distance(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the threshold is a minimum similarity, for euclid qdrant wants a maximum distance
+	var scoreThreshold *float32
+	if options.VssScoreThreshold > 0 {
+		threshold := float32(min(options.VssScoreThreshold, constants.VssScoreThresholdMax)) / constants.VssScoreThresholdMax
+		if metric == pb.Distance_Euclid {
+			threshold = 1/threshold - 1
+		}
+		scoreThreshold = &threshold
+	}
+
 	pointGroups, err := pointsClient.SearchGroups(ctx, &pb.SearchPointGroups{
 		CollectionName: orgId,
 		Vector:         vector,
@@ -24,32 +67,97 @@ func (qdr Qdr) Vss(vector []float32, orgId string, workspaceId string, options m
 				Enable: true,
 			},
 		},
-		Filter: &pb.Filter{
-			Must: []*pb.Condition{
-				{
-					ConditionOneOf: &pb.Condition_Field{
-						Field: &pb.FieldCondition{
-							Key: "workspaceId",
-							Match: &pb.Match{
-								MatchValue: &pb.Match_Text{
-									Text: workspaceId,
-								},
-							},
-						},
-					},
-				},
+		Filter:         filter,
+		ScoreThreshold: scoreThreshold,
+		WithVectors: &pb.WithVectorsSelector{
+			SelectorOptions: &pb.WithVectorsSelector_Enable{
+				Enable: options.MmrLambda > 0, // needed for diversification
 			},
 		},
 		// WithPayload     *WithPayloadSelector
 		// Params          *SearchParams
-		// ScoreThreshold  *float32
 		// VectorName      *string
-		// WithVectors     *WithVectorsSelector
 		GroupBy:   "documentId", // documentId
 		GroupSize: options.VssChunkLimit,
 		// ReadConsistency *ReadConsistency
 		// WithLookup      *WithLookup
 	})
 
-	return pointGroups.GetResult(), err
+	if err != nil {
+		return nil, err
+	}
+
+	return groupHits(pointGroups.GetResult(), metric), nil
+}
+
+// collection distances, looked up once per collection
+var distances sync.Map
+
+// distance returns the metric of an org's collection
+func (qdr Qdr) distance(orgId string) (pb.Distance, error) {
+	if metric, ok := distances.Load(orgId); ok {
+		return metric.(pb.Distance), nil
+	}
+
+	ctx, cancel := util.GetContext()
+	defer cancel()
+
+	r, err := qdr.Driver.Get(ctx, &pb.GetCollectionInfoRequest{CollectionName: orgId})
+	if err != nil {
+		return pb.Distance_UnknownDistance, err
+	}
+
+	metric := r.GetResult().GetConfig().GetParams().GetVectorsConfig().GetParams().GetDistance()
+	distances.Store(orgId, metric)
+	return metric, nil
+}
+
+// restricts a search to the workspace and, optionally, to any of the given documents and tags
+func vssFilter(workspaceId string, filter model.VssFilter) *pb.Filter {
+	must := []*pb.Condition{
+		{
+			ConditionOneOf: &pb.Condition_Field{
+				Field: &pb.FieldCondition{
+					Key: "workspaceId",
+					Match: &pb.Match{
+						MatchValue: &pb.Match_Keyword{
+							Keyword: workspaceId,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if len(filter.DocumentIDs) > 0 {
+		must = append(must, &pb.Condition{
+			ConditionOneOf: &pb.Condition_Field{
+				Field: &pb.FieldCondition{
+					Key: "documentId",
+					Match: &pb.Match{
+						MatchValue: &pb.Match_Keywords{
+							Keywords: &pb.RepeatedStrings{Strings: filter.DocumentIDs},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if len(filter.TagIDs) > 0 {
+		must = append(must, &pb.Condition{
+			ConditionOneOf: &pb.Condition_Field{
+				Field: &pb.FieldCondition{
+					Key: "tags",
+					Match: &pb.Match{
+						MatchValue: &pb.Match_Keywords{
+							Keywords: &pb.RepeatedStrings{Strings: filter.TagIDs},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return &pb.Filter{Must: must}
 }
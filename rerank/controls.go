@@ -0,0 +1,46 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"vector-ai/model"
+
+	"github.com/go-errors/errors"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// workspace rerankMode values
+const (
+	ModeNone         = 0
+	ModeLLM          = 1
+	ModeCrossEncoder = 2
+)
+
+// Reranker scores retrieved hits against the query, setting RerankScore
+// and returning the hits best first
+type Reranker interface {
+	Rerank(context.Context, string, []model.Hit) ([]model.Hit, error)
+}
+
+// New returns the reranker for a workspace's rerankMode
+func New(mode uint32, llm llms.Model) Reranker {
+	switch mode {
+	case ModeLLM:
+		return LLM{Model: llm}
+	case ModeCrossEncoder:
+		return CrossEncoder{URL: os.Getenv("RERANK_URL"), Client: http.DefaultClient}
+	default:
+		return Noop{}
+	}
+}
+
+func check(err error) error {
+	if err != nil {
+		x := errors.New(err)
+		fmt.Println(x.ErrorStack())
+		return err
+	}
+	return nil
+}
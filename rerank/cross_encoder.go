@@ -0,0 +1,83 @@
+package rerank
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"vector-ai/model"
+)
+
+// CrossEncoder calls an external reranking endpoint using the
+// text-embeddings-inference /rerank contract:
+// {"query": "...", "texts": ["..."]} -> [{"index": 0, "score": 0.98}]
+type CrossEncoder struct {
+	URL    string
+	Client *http.Client
+}
+
+type crossEncoderRequest struct {
+	Query string   `json:"query"`
+	Texts []string `json:"texts"`
+}
+
+type crossEncoderScore struct {
+	Index int     `json:"index"`
+	Score float32 `json:"score"`
+}
+
+func (r CrossEncoder) Rerank(ctx context.Context, query string, hits []model.Hit) ([]model.Hit, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+	if r.URL == "" {
+		return hits, errors.New("RERANK_URL is not set")
+	}
+
+	texts := []string{}
+	for _, hit := range hits {
+		texts = append(texts, hit.Value)
+	}
+
+	body, err := json.Marshal(crossEncoderRequest{Query: query, Texts: texts})
+	if err != nil {
+		return hits, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return hits, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.Client.Do(req)
+	if err != nil {
+		return hits, check(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return hits, fmt.Errorf("cross-encoder responded %s", res.Status)
+	}
+
+	var scores []crossEncoderScore
+	if err := json.NewDecoder(res.Body).Decode(&scores); err != nil {
+		return hits, err
+	}
+
+	for _, score := range scores {
+		if score.Index >= 0 && score.Index < len(hits) {
+			hits[score.Index].RerankScore = score.Score
+		}
+	}
+
+	slices.SortStableFunc(hits, func(a model.Hit, b model.Hit) int {
+		return cmp.Compare(b.RerankScore, a.RerankScore)
+	})
+
+	return hits, nil
+}
@@ -0,0 +1,81 @@
+package rerank
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"vector-ai/model"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+const RelevancePromptTemplate = `
+Rate how relevant each numbered passage is to the search query, from 0 (irrelevant) to 10 (directly answers it).
+
+Query: "{{.query}}"
+
+Passages:
+{{.passages}}
+
+Respond with only a JSON array of {{.count}} numbers, one score per passage in the order given, with no other text.
+`
+
+// LLM asks a chat model to score every passage in one call
+type LLM struct {
+	Model llms.Model
+}
+
+func (r LLM) Rerank(ctx context.Context, query string, hits []model.Hit) ([]model.Hit, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+
+	var passages strings.Builder
+	for i, hit := range hits {
+		fmt.Fprintf(&passages, "[%d] %s\n\n", i+1, hit.Value)
+	}
+
+	prompt := prompts.NewPromptTemplate(RelevancePromptTemplate, []string{"query", "passages", "count"})
+	constructedPrompt, err := prompt.Format(map[string]any{
+		"query":    query,
+		"passages": passages.String(),
+		"count":    len(hits),
+	})
+	if err != nil {
+		return hits, err
+	}
+
+	completion, err := llms.GenerateFromSinglePrompt(ctx, r.Model, constructedPrompt, llms.WithTemperature(0))
+	if err != nil {
+		return hits, check(err)
+	}
+
+	// tolerate code fences around the array
+	start := strings.Index(completion, "[")
+	end := strings.LastIndex(completion, "]")
+	if start < 0 || end < start {
+		return hits, fmt.Errorf("reranker returned no score array: %q", completion)
+	}
+
+	var scores []float32
+	if err := json.Unmarshal([]byte(completion[start:end+1]), &scores); err != nil {
+		return hits, err
+	}
+	if len(scores) != len(hits) {
+		return hits, fmt.Errorf("reranker returned %d scores for %d passages", len(scores), len(hits))
+	}
+
+	for i := range hits {
+		hits[i].RerankScore = scores[i] / 10
+	}
+
+	slices.SortStableFunc(hits, func(a model.Hit, b model.Hit) int {
+		return cmp.Compare(b.RerankScore, a.RerankScore)
+	})
+
+	return hits, nil
+}
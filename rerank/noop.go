@@ -0,0 +1,16 @@
+package rerank
+
+import (
+	"context"
+	"vector-ai/model"
+)
+
+// Noop keeps retrieval order and scores
+type Noop struct{}
+
+func (n Noop) Rerank(ctx context.Context, query string, hits []model.Hit) ([]model.Hit, error) {
+	for i := range hits {
+		hits[i].RerankScore = hits[i].Score
+	}
+	return hits, nil
+}
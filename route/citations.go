@@ -0,0 +1,202 @@
+package route
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"vector-ai/model"
+)
+
+// a chunk id in square brackets, as AIInstructionsBasePrompt asks for
+var citationMarker = regexp.MustCompile(`\[([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})\]`)
+
+// a marker and the spaces before it
+var citationSpan = regexp.MustCompile(`[ \t]*` + citationMarker.String())
+
+// stripCitations removes the citation markers of a reply, along with the space before each
+func stripCitations(reply string) string {
+	return strings.TrimSpace(citationSpan.ReplaceAllString(reply, ""))
+}
+
+// cite finds the citation markers in an AI reply. Markers naming a chunk that wasn't
+// supplied are removed from the reply. Each remaining marker cites the sentence before
+// it; markers side by side cite the same sentence. Spans are byte offsets into the
+// returned reply.
+func cite(reply string, supplied []model.Hit) (string, []model.Citation) {
+	documentIds := map[string]string{}
+	for _, hit := range supplied {
+		documentIds[strings.ToLower(hit.ID)] = hit.DocumentID
+	}
+
+	// drop markers that don't name a supplied chunk
+	var text strings.Builder
+	type marker struct {
+		start   int
+		end     int
+		pointId string
+	}
+	markers := []marker{}
+	last := 0
+	for _, match := range citationMarker.FindAllStringSubmatchIndex(reply, -1) {
+		pointId := strings.ToLower(reply[match[2]:match[3]])
+		before := reply[last:match[0]]
+		last = match[1]
+
+		if _, ok := documentIds[pointId]; !ok {
+			fmt.Println("AI cited a chunk it wasn't given:", pointId)
+			text.WriteString(strings.TrimRight(before, " \t"))
+			continue
+		}
+		text.WriteString(before)
+
+		start := text.Len()
+		text.WriteString(reply[match[0]:match[1]])
+		markers = append(markers, marker{start: start, end: text.Len(), pointId: pointId})
+	}
+	text.WriteString(reply[last:])
+	cited := text.String()
+
+	citations := []model.Citation{}
+	floor := 0 // end of the previous marker
+	spanStart, spanEnd := 0, 0
+	for i, m := range markers {
+		between := cited[floor:m.start]
+		if i == 0 || strings.TrimSpace(between) != "" {
+			spanStart, spanEnd = sentenceBefore(cited, floor, m.start)
+		}
+		floor = m.end
+
+		citations = append(citations, model.Citation{
+			PointID:    m.pointId,
+			DocumentID: documentIds[m.pointId],
+			Position:   int32(i),
+			Start:      int32(spanStart),
+			End:        int32(spanEnd),
+		})
+	}
+
+	return cited, citations
+}
+
+// sentenceBefore bounds the sentence ending at end, looking no further back than floor
+func sentenceBefore(text string, floor int, end int) (int, int) {
+	segment := strings.TrimRight(text[floor:end], " \t")
+	end = floor + len(segment)
+
+	// a sentence may end before its marker; that stop isn't a boundary
+	search := strings.TrimRight(segment, ".!?")
+	start := floor
+	for _, boundary := range []string{". ", "! ", "? ", "\n"} {
+		if i := strings.LastIndex(search, boundary); i >= 0 && floor+i+len(boundary) > start {
+			start = floor + i + len(boundary)
+		}
+	}
+
+	for start < end && strings.ContainsRune(" \t\n", rune(text[start])) {
+		start++
+	}
+
+	return start, end
+}
+
+// answerSpans loads the chunks a message cited, in citation order, alongside the part
+// of the message each one supports
+func (h Handler) answerSpans(orgId string, message model.Message) ([]model.AnswerSpan, error) {
+	contexts, err := h.PG.ListContextsByMessageId(message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	spans, err := h.resolveSpans(orgId, message.WorkspaceID, []model.Message{message}, contexts)
+	return spans[message.ID], err
+}
+
+// withSpans fills in the spans of each AI message of a conversation
+func (h Handler) withSpans(orgId string, conversationId string, messages []model.Message) ([]model.Message, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	contexts, err := h.PG.ListContextsByConversationId(conversationId)
+	if err != nil {
+		return messages, err
+	}
+
+	spans, err := h.resolveSpans(orgId, messages[0].WorkspaceID, messages, contexts)
+	if err != nil {
+		return messages, err
+	}
+
+	for i, message := range messages {
+		if message.AuthorType == "AI" {
+			messages[i].Spans = spans[message.ID]
+		}
+	}
+
+	return messages, nil
+}
+
+// resolveSpans looks up every cited chunk and document of the messages at once and
+// returns their spans by messageId. Spans whose chunk or document has since been
+// removed are skipped, as are rows saved before citations.
+func (h Handler) resolveSpans(orgId string, workspaceId string, messages []model.Message, contexts []model.Context) (map[string][]model.AnswerSpan, error) {
+	spans := map[string][]model.AnswerSpan{}
+
+	ids := []string{}
+	for _, context := range contexts {
+		if context.Position != nil {
+			ids = append(ids, context.PointID)
+		}
+	}
+	if len(ids) == 0 {
+		return spans, nil
+	}
+
+	points, err := h.QD.GetPointsByUuid(orgId, ids)
+	if err != nil {
+		return spans, err
+	}
+	chunks := map[string]model.Chunk{}
+	for _, point := range points {
+		chunks[point.ID] = point
+	}
+
+	documents, err := h.PG.ListDocuments(workspaceId)
+	if err != nil {
+		return spans, err
+	}
+	documentNames := map[string]string{}
+	for _, document := range documents {
+		documentNames[document.ID] = document.Name
+	}
+
+	texts := map[string]string{}
+	for _, message := range messages {
+		texts[message.ID] = message.Text
+	}
+
+	for _, context := range contexts {
+		chunk, ok := chunks[context.PointID]
+		name, named := documentNames[context.DocumentID]
+		if context.Position == nil || !ok || !named {
+			continue
+		}
+
+		span := model.AnswerSpan{
+			Position:     *context.Position,
+			Start:        context.SpanStart,
+			End:          context.SpanEnd,
+			PointID:      context.PointID,
+			DocumentID:   context.DocumentID,
+			DocumentName: name,
+			ChunkText:    chunk.Text,
+		}
+		text := texts[context.MessageID]
+		if 0 <= span.Start && span.Start <= span.End && int(span.End) <= len(text) {
+			span.Text = text[span.Start:span.End]
+		}
+		spans[context.MessageID] = append(spans[context.MessageID], span)
+	}
+
+	return spans, nil
+}
@@ -0,0 +1,102 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+	"vector-ai/model"
+)
+
+const (
+	pointA = "11111111-1111-1111-1111-111111111111"
+	pointB = "bbbbbbbb-2222-2222-2222-222222222222"
+	pointX = "99999999-9999-9999-9999-999999999999" // never supplied
+)
+
+func TestCite(t *testing.T) {
+	supplied := []model.Hit{
+		{ID: pointA, DocumentID: "doc-a"},
+		{ID: pointB, DocumentID: "doc-b"},
+	}
+
+	tests := []struct {
+		name      string
+		reply     string
+		wantReply string
+		want      []model.Citation
+	}{
+		{
+			name:      "no markers",
+			reply:     "Plain answer.",
+			wantReply: "Plain answer.",
+			want:      []model.Citation{},
+		},
+		{
+			name:      "each marker cites its sentence",
+			reply:     "Sky is blue. [" + pointA + "] Grass is green. [" + pointB + "]",
+			wantReply: "Sky is blue. [" + pointA + "] Grass is green. [" + pointB + "]",
+			want: []model.Citation{
+				{PointID: pointA, DocumentID: "doc-a", Position: 0, Start: 0, End: 12},
+				{PointID: pointB, DocumentID: "doc-b", Position: 1, Start: 52, End: 67},
+			},
+		},
+		{
+			name:      "markers side by side cite the same sentence",
+			reply:     "Both say so. [" + pointA + "][" + pointB + "]",
+			wantReply: "Both say so. [" + pointA + "][" + pointB + "]",
+			want: []model.Citation{
+				{PointID: pointA, DocumentID: "doc-a", Position: 0, Start: 0, End: 12},
+				{PointID: pointB, DocumentID: "doc-b", Position: 1, Start: 0, End: 12},
+			},
+		},
+		{
+			name:      "unsupplied markers are removed",
+			reply:     "Made up. [" + pointX + "] Real. [" + pointA + "]",
+			wantReply: "Made up. Real. [" + pointA + "]",
+			want: []model.Citation{
+				{PointID: pointA, DocumentID: "doc-a", Position: 0, Start: 9, End: 14},
+			},
+		},
+		{
+			name:      "ids match regardless of case",
+			reply:     "Upper. [BBBBBBBB-2222-2222-2222-222222222222]",
+			wantReply: "Upper. [BBBBBBBB-2222-2222-2222-222222222222]",
+			want: []model.Citation{
+				{PointID: pointB, DocumentID: "doc-b", Position: 0, Start: 0, End: 6},
+			},
+		},
+		{
+			name:      "offsets are bytes",
+			reply:     "Ünïcödé. [" + pointA + "]",
+			wantReply: "Ünïcödé. [" + pointA + "]",
+			want: []model.Citation{
+				{PointID: pointA, DocumentID: "doc-a", Position: 0, Start: 0, End: 12},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, citations := cite(tt.reply, supplied)
+			if reply != tt.wantReply {
+				t.Errorf("reply %q, want %q", reply, tt.wantReply)
+			}
+			if !reflect.DeepEqual(citations, tt.want) {
+				t.Errorf("citations %+v, want %+v", citations, tt.want)
+			}
+			for _, citation := range citations {
+				if citation.End > int32(len(reply)) || citation.Start > citation.End {
+					t.Errorf("span %d-%d out of the reply", citation.Start, citation.End)
+				}
+			}
+		})
+	}
+}
+
+func TestStripCitations(t *testing.T) {
+	reply := "title: Sky is blue [" + pointA + "]\nbody: Grass is green. [" + pointB + "][" + pointA + "]"
+	want := "title: Sky is blue\nbody: Grass is green."
+
+	if got := stripCitations(reply); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
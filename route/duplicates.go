@@ -0,0 +1,238 @@
+package route
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"vector-ai/constants"
+	"vector-ai/model"
+	"vector-ai/util"
+)
+
+// checkDuplicate looks for documents of the workspace with the same content as a
+// parsed upload. Depending on the workspace duplicateMode it does nothing, adds a
+// warning to the event stream, or tells the caller to skip the upload if canSkip.
+func (h Handler) checkDuplicate(evs model.EventStream, md model.ManifestData, parsedDoc string, canSkip bool) (model.EventStream, bool) {
+	workspaceId := md.WorkspaceID
+	documentId := md.DocumentID
+
+	configs, err := h.PG.ListWorkspaceConfigs(workspaceId)
+	if err != nil {
+		softCheck(err)
+		return evs, false
+	}
+
+	mode := util.MarshalVssOptions(configs).DuplicateMode
+	if mode == model.DuplicateModeOff || !hashable(evs, parsedDoc) {
+		return evs, false
+	}
+
+	documents, err := h.PG.ListDocumentsByContentHash(workspaceId, util.ContentHash(parsedDoc))
+	if err != nil {
+		softCheck(err)
+		return evs, false
+	}
+
+	names := []string{}
+	for _, document := range documents {
+		if document.ID != documentId { // updated drive documents match themselves
+			names = append(names, document.Name)
+		}
+	}
+	if len(names) == 0 {
+		return evs, false
+	}
+
+	event := model.UploadEvent{Operation: "Deduplicating", Action: "Duplicate", Detail: fmt.Sprintf("Same content as %s", strings.Join(names, ", "))}
+	skip := canSkip && mode == model.DuplicateModeSkip
+	if skip {
+		event.Action = "Skipped"
+	}
+
+	h.TR.Broadcast(model.UploadStatus(event, workspaceId, documentId, progress(event.Operation)))
+	evs.Events = append(evs.Events, event)
+
+	if skip {
+		h.broadcast("Operation", "Completed", workspaceId, documentId, nil)
+	}
+
+	return evs, skip
+}
+
+// hashable reports whether an upload parsed to some text. Failed and empty parses
+// would all share one hash.
+func hashable(evs model.EventStream, parsedDoc string) bool {
+	for _, event := range evs.Events {
+		if event.Operation == "Parsing" && event.Action == "Failed" {
+			return false
+		}
+	}
+	return strings.TrimSpace(parsedDoc) != ""
+}
+
+// setContentHash records the hash of an upload's parsed text. Uploads without text
+// clear it, so an updated document doesn't keep the hash of its previous version.
+func (h Handler) setContentHash(evs model.EventStream, documentId string, parsedDoc string) {
+	contentHash := ""
+	if hashable(evs, parsedDoc) {
+		contentHash = util.ContentHash(parsedDoc)
+	}
+	softCheck(h.PG.SetDocumentContentHash(documentId, contentHash))
+}
+
+// withoutSkipped drops new Drive files that were skipped as duplicates and haven't
+// changed since
+func withoutSkipped(items []model.NewDriveItem, skipped map[string]time.Time) []model.NewDriveItem {
+	return slices.DeleteFunc(items, func(item model.NewDriveItem) bool {
+		lastModified, ok := skipped[item.DriveID]
+		return ok && lastModified.Equal(item.LastModified)
+	})
+}
+
+// DuplicateReport groups documents with identical content hashes and pairs documents
+// whose chunk vectors mostly overlap. Near duplicates are found by comparing document
+// centroids first, then chunk by chunk for the closest pairs only. The comparison is
+// bounded by the NearDuplicate limits so the report stays fast on large workspaces.
+func (h Handler) DuplicateReport(orgId string, workspaceId string) (model.DuplicateReport, error) {
+	report := model.DuplicateReport{
+		WorkspaceID: workspaceId,
+		Exact:       []model.DuplicateGroup{},
+		Near:        []model.NearDuplicate{},
+		Unhashed:    []model.Document{},
+	}
+
+	documents, err := h.PG.ListDocuments(workspaceId)
+	if err != nil {
+		return report, err
+	}
+	byId := map[string]model.Document{}
+	for _, document := range documents {
+		byId[document.ID] = document
+	}
+
+	hashes, err := h.PG.ListDocumentContentHashes(workspaceId)
+	if err != nil {
+		return report, err
+	}
+
+	groups := map[string][]model.Document{}
+	for documentId, contentHash := range hashes {
+		if document, ok := byId[documentId]; ok {
+			groups[contentHash] = append(groups[contentHash], document)
+		}
+	}
+	for _, document := range documents {
+		if _, ok := hashes[document.ID]; !ok {
+			report.Unhashed = append(report.Unhashed, document)
+		}
+	}
+	slices.SortFunc(report.Unhashed, func(a model.Document, b model.Document) int { return strings.Compare(a.Name, b.Name) })
+	for contentHash, group := range groups {
+		if len(group) > 1 {
+			slices.SortFunc(group, func(a model.Document, b model.Document) int { return strings.Compare(a.Name, b.Name) })
+			report.Exact = append(report.Exact, model.DuplicateGroup{ContentHash: contentHash, Documents: group})
+		}
+	}
+	slices.SortFunc(report.Exact, func(a model.DuplicateGroup, b model.DuplicateGroup) int {
+		return strings.Compare(a.Documents[0].Name, b.Documents[0].Name)
+	})
+
+	// chunk vectors by document, up to NearDuplicateChunks each
+	chunks := map[string][][]float32{}
+	it := h.QD.Scroll(orgId, workspaceId, true)
+	for it.Next() {
+		for _, chunk := range it.Page() {
+			if len(chunks[chunk.DocumentID]) == constants.NearDuplicateChunks {
+				report.Truncated = true
+				continue
+			}
+			chunks[chunk.DocumentID] = append(chunks[chunk.DocumentID], chunk.Vector)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return report, err
+	}
+
+	documentIds := []string{}
+	centroids := map[string][]float32{}
+	for documentId := range chunks {
+		if _, ok := byId[documentId]; ok {
+			documentIds = append(documentIds, documentId)
+		}
+	}
+	slices.Sort(documentIds)
+	if len(documentIds) > constants.NearDuplicateDocuments {
+		documentIds = documentIds[:constants.NearDuplicateDocuments]
+		report.Truncated = true
+	}
+	for _, documentId := range documentIds {
+		centroids[documentId] = util.Centroid(chunks[documentId])
+	}
+
+	// close pairs, closest first
+	type pair struct {
+		a, b       string
+		similarity float32
+	}
+	pairs := []pair{}
+	for i, a := range documentIds {
+		for _, b := range documentIds[i+1:] {
+			if hashes[a] != "" && hashes[a] == hashes[b] {
+				continue // already reported as exact
+			}
+			if similarity := util.CosineSimilarity(centroids[a], centroids[b]); similarity >= constants.NearDuplicateCentroid {
+				pairs = append(pairs, pair{a, b, similarity})
+			}
+		}
+	}
+	slices.SortStableFunc(pairs, func(x pair, y pair) int { return cmp.Compare(y.similarity, x.similarity) })
+	if len(pairs) > constants.NearDuplicatePairs {
+		pairs = pairs[:constants.NearDuplicatePairs]
+		report.Truncated = true
+	}
+
+	for _, p := range pairs {
+		overlap := chunkOverlap(chunks[p.a], chunks[p.b])
+		if overlap >= constants.NearDuplicateOverlap {
+			report.Near = append(report.Near, model.NearDuplicate{
+				Documents: []model.Document{byId[p.a], byId[p.b]},
+				Overlap:   overlap,
+			})
+		}
+	}
+	slices.SortFunc(report.Near, func(a model.NearDuplicate, b model.NearDuplicate) int {
+		if a.Overlap > b.Overlap {
+			return -1
+		} else if a.Overlap < b.Overlap {
+			return 1
+		}
+		return 0
+	})
+
+	return report, nil
+}
+
+// chunkOverlap is the share of the smaller document's chunks that have a near
+// identical chunk in the other document
+func chunkOverlap(a [][]float32, b [][]float32) float32 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a) == 0 {
+		return 0
+	}
+
+	matched := 0
+	for _, x := range a {
+		for _, y := range b {
+			if util.CosineSimilarity(x, y) >= constants.NearDuplicateChunk {
+				matched++
+				break
+			}
+		}
+	}
+
+	return float32(matched) / float32(len(a))
+}
@@ -0,0 +1,109 @@
+package route
+
+import (
+	bg "context"
+	"slices"
+	"testing"
+	"vector-ai/constants"
+	"vector-ai/model"
+	pgx "vector-ai/postgres"
+	"vector-ai/provider"
+	"vector-ai/qdrant"
+
+	pg "github.com/jackc/pgx/v5"
+	"github.com/tmc/langchaingo/embeddings"
+)
+
+const testOrg = "org_test"
+
+// fakePG serves the postgres calls the handler tests make. Anything else panics on
+// the nil Controls.
+type fakePG struct {
+	pgx.Controls
+	configs   []model.WorkspaceConfig
+	documents map[string][]model.Document // by workspaceId
+	reindexed []string
+}
+
+func (p *fakePG) ListWorkspaceConfigs(workspaceId string) ([]model.WorkspaceConfig, error) {
+	return p.configs, nil
+}
+
+func (p *fakePG) ListWorkspaces(orgId string) ([]model.Workspace, error) {
+	workspaces := []model.Workspace{}
+	for workspaceId := range p.documents {
+		workspaces = append(workspaces, model.Workspace{ID: workspaceId, OrgID: orgId})
+	}
+	return workspaces, nil
+}
+
+func (p *fakePG) ListDocuments(workspaceId string) ([]model.Document, error) {
+	return p.documents[workspaceId], nil
+}
+
+func (p *fakePG) GetDocument(documentId string) (model.Document, error) {
+	for _, documents := range p.documents {
+		for _, document := range documents {
+			if document.ID == documentId {
+				return document, nil
+			}
+		}
+	}
+	return model.Document{}, pg.ErrNoRows
+}
+
+func (p *fakePG) FlagDocumentReindex(documentId string) error {
+	p.reindexed = append(p.reindexed, documentId)
+	return nil
+}
+
+func (p *fakePG) DeleteChunksByDocumentId(documentId string) error {
+	return nil
+}
+
+// config sets a workspace config property, as CreateWorkspace would
+func (p *fakePG) config(property string, value int64) {
+	p.configs = slices.DeleteFunc(p.configs, func(config model.WorkspaceConfig) bool { return config.Property == property })
+	p.configs = append(p.configs, model.WorkspaceConfig{Property: property, Value: value})
+}
+
+// newTestHandler runs a handler offline: the memory store with an empty collection,
+// the fake embedder and, for workspaces, the fake llm
+func newTestHandler(t *testing.T) (Handler, *fakePG, *embeddings.EmbedderImpl) {
+	t.Setenv("VECTOR_STORE", "memory")
+
+	store := qdrant.NewMemory()
+	if err := store.CreateCollection(testOrg, constants.VectorSize, "cosine"); err != nil {
+		t.Fatal(err)
+	}
+
+	embedder, err := provider.NewEmbedder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakePG{documents: map[string][]model.Document{}}
+	fake.config("vssDocumentLimit", 3)
+	fake.config("vssChunkLimit", 2)
+	fake.config("llmProvider", int64(provider.Default()))
+
+	return Handler{QD: store, PG: fake, TR: NewTracker(), EM: embedder}, fake, embedder
+}
+
+// upload embeds and stores a document's chunks, recording the document unless orphan
+func upload(t *testing.T, h Handler, embedder *embeddings.EmbedderImpl, workspaceId string, documentId string, chunks []string, orphan bool) {
+	floats, err := embedder.EmbedDocuments(bg.Background(), chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.QD.Upload(testOrg, workspaceId, documentId, floats, chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	if !orphan {
+		fake := h.PG.(*fakePG)
+		fake.documents[workspaceId] = append(fake.documents[workspaceId], model.Document{
+			ID: documentId, WorkspaceID: workspaceId, Name: documentId, Vectors: int64(len(chunks)),
+		})
+	}
+}
@@ -4,27 +4,68 @@ import (
 	bg "context"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 	"vector-ai/model"
+	"vector-ai/provider"
 	"vector-ai/util"
 
-	pb "github.com/qdrant/go-client/qdrant"
+	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/prompts"
 )
 
-const VssFromPromptTemplate = `
+const VssFromPromptTemplate = `{{if .history}}
+given the conversation so far:
+
+{{.history}}
+{{end}}
 take the following LLM prompt:
 
 "{{.prompt}}"
 
 And Create a vector similarity search query from it, only extracting terms the user might be searching for.
 
-Focus on search terms and always ignore terms that are used as directions to an LLM.
+Focus on search terms and always ignore terms that are used as directions to an LLM.{{if .history}} Replace references to earlier turns, such as "it" or "the second one", with the terms they refer to.{{end}}
 
 For your output, only provide the most revelant search terms, space-separated, with no other conversational verbiage of any kind. This response is intended to be used directly in a vector similarity search.
 `
 
+const MultiQueryPromptTemplate = `{{if .history}}
+given the conversation so far:
+
+{{.history}}
+{{end}}
+take the following LLM prompt:
+
+"{{.prompt}}"
+
+It may touch on several topics. Write {{.count}} different vector similarity search queries that together cover every topic the user might be searching for.
+
+Focus on search terms and always ignore terms that are used as directions to an LLM.{{if .history}} Replace references to earlier turns, such as "it" or "the second one", with the terms they refer to.{{end}}
+
+For your output, provide one query per line, each made of space-separated search terms, with no numbering and no other conversational verbiage of any kind.
+`
+
+const HydePromptTemplate = `{{if .history}}
+given the conversation so far:
+
+{{.history}}
+{{end}}
+take the following LLM prompt:
+
+"{{.prompt}}"
+
+Write a short passage, as it might appear in one of the user's documents, that would answer it. Do not mention the prompt or that the passage is hypothetical.
+
+This response is intended to be used directly in a vector similarity search.
+`
+
+// upper bound on generated sub-queries, each costs a search
+const maxSubQueries = 5
+
 const AIInstructionsBasePrompt = `
 {{.instructions}}
 
@@ -39,13 +80,43 @@ Relevant pieces of information:
 {{.context}}
 
 If any of this information is irrelevant, it can be discarded.
-
+{{if .citations}}
+After each sentence that uses a piece of information, cite it by writing the "id" of that piece in square brackets, such as [id]. Only cite ids given above.
+{{end}}
 Current conversation:
-Human: {{.query}}
+{{.history}}Human: {{.query}}
 Raw AI YAML Response:
 `
 
-func (s Session) QueryAnalysis(m model.WebSocketsMessage) model.Message {
+const RepairPromptTemplate = `
+{{.prompt}}{{.response}}
+
+That response could not be used: {{.error}}
+
+Reply again with the corrected raw YAML only, following the YAML Schema above, with no outside conversational text and no code fences.
+`
+
+// retries after the first reply fails schema validation
+const maxRepairAttempts = 2
+
+func (s Session) QueryAnalysis(m model.WebSocketsMessage) (model.Message, error) {
+	if s.token != nil {
+		m.UserID = s.token.Claims.(*model.ClerkClaims).Subject
+	}
+
+	message, err := s.handler.analyze(s.embedder, s.orgId, m, s.tracker.Broadcast)
+	if err != nil {
+		check(err)
+		s.tracker.Broadcast(model.QueryError(err.Error(), m.WorkspaceID, m.ConversationID))
+	}
+
+	return message, err
+}
+
+// analyze saves the user's message, builds context, queries the workspace's chat model
+// and saves its reply. Progress goes out through emit as the same envelopes the
+// websocket broadcasts.
+func (h Handler) analyze(embedder *embeddings.EmbedderImpl, orgId string, m model.WebSocketsMessage, emit func(model.Envelope)) (model.Message, error) {
 
 	workspaceId := m.WorkspaceID
 	conversationId := m.ConversationID
@@ -57,163 +128,362 @@ func (s Session) QueryAnalysis(m model.WebSocketsMessage) model.Message {
 	timestamp := time.Now().Format(time.RFC3339)
 
 	var context string
-	var results []*pb.ScoredPoint
-	var groupsResult *pb.GroupsResult
+	var supplied []model.Hit // chunks the AI is given, and may cite
 	ctx := bg.Background()
 
-	// Get conversation history for use in s.constructHistory (if constructHistory {...
-	// conversationHistory, err := s.handler.PG.ListMessages(s.conversationId)
-	// check(err
-	// ...}
+	configs, err := h.PG.ListWorkspaceConfigs(workspaceId)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	options := util.MarshalVssOptions(configs)
+
+	llm, modelName, err := provider.New(options.LlmProvider, options.LlmModel)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	// fill in the template's variables before anything is saved or prompted
+	template, err := h.PG.GetTemplate(templateId)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	values, err := util.TemplateValues(template.Variables, m.Variables)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	instructions, err := util.RenderTemplate(template.Text, template.Variables, values)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	// earlier turns, read before this message is saved
+	var history string
+	if options.HistoryTurns > 0 {
+		messages, err := h.PG.ListMessages(conversationId)
+		if err != nil {
+			return model.Message{}, err
+		}
+		history = conversationHistory(messages, options.HistoryTurns, options.HistoryTokens, modelName)
+	}
 
 	// Save message to postgres
-	pgMessage, err := s.handler.PG.CreateMessage(workspaceId, conversationId, templateId, query, "Human", authorName, timestamp)
-	check(err)
+	pgMessage, err := h.PG.CreateMessage(workspaceId, conversationId, templateId, query, "Human", authorName, timestamp)
+	if err != nil {
+		return model.Message{}, err
+	}
 
-	s.tracker.Broadcast(model.UserResponse(pgMessage, workspaceId, conversationId))
+	emit(model.UserResponse(pgMessage, workspaceId, conversationId))
 
 	if len(forceContext) > 0 {
 		context = forceContext
 	} else {
 
-		s.tracker.Broadcast(model.QueryStatus("Constructing VSS with AI...", workspaceId, conversationId))
-		prompt := prompts.NewPromptTemplate(VssFromPromptTemplate, []string{"prompt"})
+		emit(model.QueryStatus("Constructing VSS with AI...", workspaceId, conversationId))
+		prompt := prompts.NewPromptTemplate(VssFromPromptTemplate, []string{"prompt", "history"})
 		constructedPrompt, err := prompt.Format(map[string]any{
-			"prompt": query,
+			"prompt":  query,
+			"history": history,
 		})
-		check(err)
+		if err != nil {
+			return model.Message{}, err
+		}
 
 		//chatQuery := s.constructSingle(constructedPrompt)
 
 		// Call AI including chat history
-		completion, err := s.chatbot.Call(ctx, constructedPrompt) // , llms.WithFunctions(functions))
-		check(err)
-
-		s.tracker.Broadcast(model.QueryStatus("Performing Vector Similarity Search...", workspaceId, conversationId))
-		// Vectorizing query body...
-		floats, err := s.embedder.EmbedQuery(ctx, completion)
-		check(err)
-
-		_, err = s.handler.QD.GetCollection(s.orgId)
-		if err == nil {
-
-			// Get configs and Create a struct from them
-			configs, err := s.handler.PG.ListWorkspaceConfigs(workspaceId)
-			check(err)
-
-			options := util.MarshalVssOptions(configs)
-
-			groupsResult, err = s.handler.QD.Vss(floats, s.orgId, workspaceId, options) // [0.1, 0.6, 0.5...]
-			check(err)
-
-			pointGroups := groupsResult.GetGroups()
-
-			chunksByDocId := map[string]*model.ChunkValues{}
-
-			for _, pg := range pointGroups {
-				results = pg.GetHits()
-
-				// collect vss results into context for the AI
-				for _, point := range results {
-
-					pointId := point.GetId().GetUuid()
-					payloadMap := point.GetPayload()
-					score := point.GetScore()
-					payloadDocId := payloadMap["documentId"]
-					documentId := payloadDocId.GetStringValue()
-					payloadChunk := payloadMap["chunk"]
-					val := payloadChunk.GetStringValue()
-
-					// fmt.Println("chunk", val)
+		completion, vssUsage, err := provider.Generate(ctx, llm, constructedPrompt) // , llms.WithFunctions(functions))
+		if err != nil {
+			return model.Message{}, err
+		}
 
-					scoredChunk := model.ScoredChunk{ID: pointId, Value: val, Score: score}
+		// optionally search along several sub-queries and a hypothetical answer
+		queries := []string{completion}
+		if options.MultiQuery > 0 || options.HydeQuery > 0 {
+			emit(model.QueryStatus("Expanding VSS query with AI...", workspaceId, conversationId))
+			expanded, expandUsage, err := expandQuery(ctx, llm, query, history, options)
+			if err != nil {
+				return model.Message{}, err
+			}
+			queries = append(queries, expanded...)
+			vssUsage = vssUsage.Add(expandUsage)
+		}
 
-					// fmt.Println("scores", payloadMap, score, documentId)
+		emit(model.QueryStatus("Performing Vector Similarity Search...", workspaceId, conversationId))
 
-					_, keyExists := chunksByDocId[documentId]
-					if !keyExists { // Create key
-						chunksByDocId[documentId] = &model.ChunkValues{}
-					}
+		filter := model.VssFilter{DocumentIDs: m.DocumentIDs, TagIDs: m.TagIDs}
 
-					chunksByDocId[documentId].AddChunk(scoredChunk)
+		hits, err := h.retrieveQueries(embedder, llm, orgId, workspaceId, queries, filter)
+		if err != nil {
+			return model.Message{}, err
+		}
 
-					if err != nil {
-						fmt.Printf("loader error: %s", err)
-					}
-				}
+		// keyword search embeds nothing
+		if options.VssMode != model.VssModeKeyword {
+			vssUsage = vssUsage.Add(provider.EmbeddingUsage(queries))
+		}
+		h.recordUsage(usage(vssUsage, model.UsageVss, modelName, orgId, pgMessage, m.UserID))
+
+		// optionally widen each hit into a passage with its neighbouring chunks
+		passages := hits
+		if options.ContextExpansion > 0 {
+			passages, err = h.expandHits(orgId, hits, int64(options.ContextExpansion))
+			if err != nil {
+				return model.Message{}, err
 			}
+		}
 
-			var ch model.ContextHolder
-			// Create a ContextLoader and add to ContextHolder
-			for docId, cv := range chunksByDocId {
-				document, _ := s.handler.PG.GetDocument(docId)
-				ch.AddLoader(model.ContextLoaderScored{DocumentId: docId, DocumentName: document.Name, Context: cv})
-			}
-			ch.Query = completion
-			s.tracker.Broadcast(model.VssResponse(ch, workspaceId, conversationId))
+		// collect vss results into context for the AI
+		supplied = passages
+		ch := h.contextHolder(passages, completion)
+		if len(queries) > 1 {
+			ch.Queries = queries
+		}
+		emit(model.VssResponse(ch, workspaceId, conversationId))
 
-			// convert to json
-			jsonBytes, err := json.Marshal(ch)
-			check(err)
-			context = string(jsonBytes)
+		// convert to json
+		jsonBytes, err := json.Marshal(ch)
+		if err != nil {
+			return model.Message{}, err
 		}
+		context = string(jsonBytes)
 	}
 
-	s.tracker.Broadcast(model.QueryStatus("Building prompt...", workspaceId, conversationId))
+	emit(model.QueryStatus("Building prompt...", workspaceId, conversationId))
 
 	// Building prompt...
-	template, err := s.handler.PG.GetTemplate(templateId)
-	check(err)
-
-	prompt := prompts.NewPromptTemplate(AIInstructionsBasePrompt, []string{"context", "query"})
+	prompt := prompts.NewPromptTemplate(AIInstructionsBasePrompt, []string{"context", "query", "history"})
 	constructedPrompt, err := prompt.Format(map[string]any{
-		"instructions":   template.Text,
+		"instructions":   instructions,
 		"context":        context,
+		"history":        history,
 		"query":          query,
 		"responseSchema": responseSchema,
+		"citations":      len(supplied) > 0,
 	})
-	check(err)
+	if err != nil {
+		return model.Message{}, err
+	}
 
 	// fmt.Println(constructedPrompt)
 
-	s.tracker.Broadcast(model.QueryStatus("Querying AI...", workspaceId, conversationId))
-
-	// chatHistory := s.construct(conversationHistory, constructedPrompt)
-	//chatQuery := s.constructSingle(constructedPrompt)
+	emit(model.QueryStatus("Querying AI...", workspaceId, conversationId))
 
-	// Call AI including chat history
-	//completion, err := s.chatbot.Call(ctx, chatQuery) // , llms.WithFunctions(functions))
-
-	completion, err := s.chatbot.Call(
-		ctx,
-		constructedPrompt,
+	callOptions := append(provider.CallOptions(options.LlmTemperature),
 		llms.WithStreamingFunc(func(ctx bg.Context, chunk []byte) error {
-			s.tracker.Broadcast(model.AiStreamChunk(chunk, s.workspaceId, s.conversationId))
+			emit(model.AiStreamChunk(chunk, workspaceId, conversationId))
 			return nil
 		}),
 	)
 
-	check(err)
+	completion, analysisUsage, err := provider.Generate(ctx, llm, constructedPrompt, callOptions...)
+	if err != nil {
+		return model.Message{}, err
+	}
 
 	reply := completion
 
+	// parse the YAML reply, asking the AI to fix it if it doesn't match the schema
+	var structured []byte
+	var valid bool
+	if strings.TrimSpace(responseSchema) != "" {
+		var repairUsage model.TokenUsage
+		reply, structured, valid, repairUsage, err = repairReply(ctx, llm, provider.CallOptions(options.LlmTemperature), constructedPrompt, reply, responseSchema, func() {
+			emit(model.QueryStatus("Repairing AI response...", workspaceId, conversationId))
+		})
+		analysisUsage = analysisUsage.Add(repairUsage)
+		if err != nil {
+			return model.Message{}, err
+		}
+	}
+
+	// keep the citations of chunks the AI was actually given
+	reply, citations := cite(reply, supplied)
+
 	timestamp = time.Now().Format(time.RFC3339) // new timestamp
-	message, err := s.handler.PG.CreateAIMessage(workspaceId, conversationId, reply, "AI", "gpt-4-1106-preview", timestamp)
-	check(err)
-
-	// set context
-	for _, point := range results {
-		pointId := point.GetId().GetUuid()
-		payloadMap := point.GetPayload()
-		payloadDocId := payloadMap["documentId"]
-		documentId := payloadDocId.GetStringValue()
-
-		//for documentId := range payloadMap {
-		_, err = s.handler.PG.CreateContext(documentId, message.ID, pointId)
-		check(err)
-		//}
+	message, err := h.PG.CreateAIMessage(workspaceId, conversationId, templateId, template.Version, reply, "AI", modelName, modelName, timestamp)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	h.recordUsage(usage(analysisUsage, model.UsageAnalysis, modelName, orgId, message, m.UserID))
+
+	if strings.TrimSpace(responseSchema) != "" {
+		message, err = h.PG.SetMessageStructured(message.ID, structured, valid)
+		if err != nil {
+			return message, err
+		}
+	}
+
+	// set context, only for cited chunks
+	for _, citation := range citations {
+		_, err = h.PG.CreateContext(message.ID, citation)
+		if err != nil {
+			return message, err
+		}
 	}
 
-	return message
+	// the reply is saved, its spans load again with the message
+	message.Spans, err = h.answerSpans(orgId, message)
+	if err != nil {
+		fmt.Println("could not load answer spans:", err)
+	}
+
+	return message, nil
+}
+
+// expandQuery asks the LLM for the workspace's configured number of sub-queries
+// and, if enabled, a hypothetical answer (HyDE) to search with
+func expandQuery(ctx bg.Context, llm llms.Model, query string, history string, options model.VssOptions) ([]string, model.TokenUsage, error) {
+	queries := []string{}
+	var spent model.TokenUsage
+
+	if options.MultiQuery > 0 {
+		count := min(options.MultiQuery, maxSubQueries)
+
+		prompt := prompts.NewPromptTemplate(MultiQueryPromptTemplate, []string{"prompt", "count", "history"})
+		constructedPrompt, err := prompt.Format(map[string]any{
+			"prompt":  query,
+			"count":   count,
+			"history": history,
+		})
+		if err != nil {
+			return nil, spent, err
+		}
+
+		completion, callUsage, err := provider.Generate(ctx, llm, constructedPrompt)
+		if err != nil {
+			return nil, spent, err
+		}
+		spent = spent.Add(callUsage)
+
+		subQueries := parseQueries(completion)
+		queries = append(queries, subQueries[:min(len(subQueries), int(count))]...)
+	}
+
+	if options.HydeQuery > 0 {
+		prompt := prompts.NewPromptTemplate(HydePromptTemplate, []string{"prompt", "history"})
+		constructedPrompt, err := prompt.Format(map[string]any{
+			"prompt":  query,
+			"history": history,
+		})
+		if err != nil {
+			return nil, spent, err
+		}
+
+		completion, callUsage, err := provider.Generate(ctx, llm, constructedPrompt)
+		if err != nil {
+			return nil, spent, err
+		}
+		spent = spent.Add(callUsage)
+
+		if hyde := strings.TrimSpace(completion); hyde != "" {
+			queries = append(queries, hyde)
+		}
+	}
+
+	return queries, spent, nil
+}
+
+var listMarker = regexp.MustCompile(`^([-*•]|\d+[.)])\s*`)
+
+// parseQueries reads one query per line, dropping list markers the LLM adds anyway
+func parseQueries(completion string) []string {
+	queries := []string{}
+
+	for _, line := range strings.Split(completion, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.Trim(line, "\"")
+		if line != "" {
+			queries = append(queries, line)
+		}
+	}
+
+	return queries
+}
+
+// conversationHistory renders the last turns of a conversation as "Human:"/"AI:"
+// lines. A turn is a message and its replies. Whole turns are dropped, oldest
+// first, once the token budget is spent; a budget of 0 keeps every turn. Tokens
+// are counted for modelName, see provider.CountTokens.
+func conversationHistory(messages []model.Message, turns uint32, tokenBudget uint32, modelName string) string {
+	grouped := [][]model.Message{}
+	for _, message := range messages {
+		if message.AuthorType == "Human" || len(grouped) == 0 {
+			grouped = append(grouped, []model.Message{})
+		}
+		grouped[len(grouped)-1] = append(grouped[len(grouped)-1], message)
+	}
+	grouped = grouped[max(0, len(grouped)-int(turns)):]
+
+	kept := []string{}
+	tokens := 0
+	for i := len(grouped) - 1; i >= 0; i-- {
+		turn := ""
+		for _, message := range grouped[i] {
+			turn += fmt.Sprintf("%s: %s\n", message.AuthorType, strings.TrimSpace(message.Text))
+		}
+
+		tokens += provider.CountTokens(modelName, turn)
+		if tokenBudget > 0 && tokens > int(tokenBudget) {
+			break
+		}
+		kept = append(kept, turn)
+	}
+
+	slices.Reverse(kept)
+	return strings.Join(kept, "")
+}
+
+// repairReply validates a YAML reply against the response schema. A reply that fails
+// is sent back to the AI with the validation error, up to maxRepairAttempts times.
+// Citation markers are left out of the parsed JSON and the repair prompt. It returns
+// the last reply, its JSON (nil if it never parsed), whether it matched and the tokens
+// the repairs spent.
+func repairReply(ctx bg.Context, llm llms.Model, callOptions []llms.CallOption, constructedPrompt string, reply string, schema string, repairing func()) (string, []byte, bool, model.TokenUsage, error) {
+	structured, validationErr := util.ParseStructured(stripCitations(reply), schema)
+	var spent model.TokenUsage
+
+	for attempt := 0; validationErr != nil && attempt < maxRepairAttempts; attempt++ {
+		repairing()
+
+		prompt := prompts.NewPromptTemplate(RepairPromptTemplate, []string{"prompt", "response", "error"})
+		repairPrompt, err := prompt.Format(map[string]any{
+			"prompt":   constructedPrompt,
+			"response": stripCitations(reply),
+			"error":    validationErr.Error(),
+		})
+		if err != nil {
+			return reply, structured, false, spent, err
+		}
+
+		completion, callUsage, err := provider.Generate(ctx, llm, repairPrompt, callOptions...)
+		if err != nil {
+			return reply, structured, false, spent, err
+		}
+		spent = spent.Add(callUsage)
+
+		reply = completion
+		structured, validationErr = util.ParseStructured(stripCitations(reply), schema)
+	}
+
+	if validationErr != nil {
+		fmt.Println("AI response failed validation:", validationErr)
+	}
+
+	return reply, structured, validationErr == nil, spent, nil
+}
 
+// usage fills in who and what a call's tokens were spent on
+func usage(spent model.TokenUsage, operation string, modelName string, orgId string, message model.Message, userId string) model.TokenUsage {
+	spent.Operation = operation
+	spent.Model = modelName
+	spent.OrgID = orgId
+	spent.WorkspaceID = message.WorkspaceID
+	spent.ConversationID = message.ConversationID
+	spent.MessageID = message.ID
+	spent.UserID = userId
+	return spent
 }
@@ -0,0 +1,75 @@
+package route
+
+import (
+	"testing"
+	"vector-ai/model"
+)
+
+func TestConversationHistory(t *testing.T) {
+	human := func(text string) model.Message { return model.Message{AuthorType: "Human", Text: text} }
+	ai := func(text string) model.Message { return model.Message{AuthorType: "AI", Text: text} }
+
+	conversation := []model.Message{human("h1"), ai("a1"), human("h2"), ai("a2"), ai("a2 again"), human("h3"), ai("a3")}
+
+	// "Human: h3\nAI: a3\n" is 17 characters, an estimated 4 tokens for the fake model
+	tests := []struct {
+		name     string
+		messages []model.Message
+		turns    uint32
+		budget   uint32
+		want     string
+	}{
+		{
+			name:     "no messages",
+			messages: []model.Message{},
+			turns:    3,
+			want:     "",
+		},
+		{
+			name:     "turns are capped, oldest dropped",
+			messages: conversation,
+			turns:    1,
+			want:     "Human: h3\nAI: a3\n",
+		},
+		{
+			name:     "ai replies stay with their message",
+			messages: conversation,
+			turns:    2,
+			want:     "Human: h2\nAI: a2\nAI: a2 again\nHuman: h3\nAI: a3\n",
+		},
+		{
+			name:     "more turns than the conversation has",
+			messages: conversation,
+			turns:    10,
+			want:     "Human: h1\nAI: a1\nHuman: h2\nAI: a2\nAI: a2 again\nHuman: h3\nAI: a3\n",
+		},
+		{
+			name:     "a reply without a message is a turn",
+			messages: []model.Message{ai("welcome"), human("h1")},
+			turns:    10,
+			want:     "AI: welcome\nHuman: h1\n",
+		},
+		{
+			name:     "budget drops whole turns",
+			messages: conversation,
+			turns:    10,
+			budget:   10,
+			want:     "Human: h3\nAI: a3\n",
+		},
+		{
+			name:     "budget below the last turn keeps nothing",
+			messages: conversation,
+			turns:    10,
+			budget:   3,
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conversationHistory(tt.messages, tt.turns, tt.budget, "fake"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
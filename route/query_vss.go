@@ -1,12 +1,8 @@
 package route
 
 import (
-	bg "context"
-	"fmt"
+	"net/http"
 	"vector-ai/model"
-	"vector-ai/util"
-
-	pb "github.com/qdrant/go-client/qdrant"
 )
 
 //
@@ -18,77 +14,43 @@ func (s Session) QueryVss(m model.WebSocketsMessage) {
 
 	workspaceId := m.WorkspaceID
 	conversationId := m.ConversationID
-	// templateId := m.TemplateID.String
-	// authorName := m.AuthorName
 	vssText := m.VssText
-	// timestamp := time.Now().Format(time.RFC3339)
-
-	// var context string
-	var results []*pb.ScoredPoint
-	var groupsResult *pb.GroupsResult
-
-	ctx := bg.Background()
-
-	// Vectorizing query body...
-	floats, err := s.embedder.EmbedQuery(ctx, vssText)
-	check(err)
 
-	_, err = s.handler.QD.GetCollection(s.orgId)
-	if err == nil {
+	filter := model.VssFilter{DocumentIDs: m.DocumentIDs, TagIDs: m.TagIDs}
 
-		// Get configs and create a struct from them
-		configs, err := s.handler.PG.ListWorkspaceConfigs(workspaceId)
+	llm, _, err := s.handler.workspaceLLM(workspaceId)
+	if err != nil {
 		check(err)
+		return
+	}
 
-		options := util.MarshalVssOptions(configs)
-
-		// perform vss query
-		groupsResult, err = s.handler.QD.Vss(floats, s.orgId, workspaceId, options) // [0.1, 0.6, 0.5...]
-		check(err)
-
-		pointGroups := groupsResult.GetGroups()
-
-		chunksByDocId := map[string]*model.ChunkValues{}
-
-		for _, pg := range pointGroups {
-			results = pg.GetHits()
-
-			// collect vss results into context for the AI
-			for _, point := range results {
-				pointId := point.GetId().GetUuid()
-				payloadMap := point.GetPayload()
-				score := point.GetScore()
-				payloadDocId := payloadMap["documentId"]
-				documentId := payloadDocId.GetStringValue()
-				payloadChunk := payloadMap["chunk"]
-				val := payloadChunk.GetStringValue()
+	// perform vss query
+	hits, err := s.handler.retrieve(s.embedder, llm, s.orgId, workspaceId, vssText, filter)
+	check(err)
 
-				scoredChunk := model.ScoredChunk{ID: pointId, Value: val, Score: score}
+	ch := s.handler.contextHolder(hits, vssText)
 
-				// fmt.Println("scores", payloadMap, score, documentId)
+	// broadcast json to frontend
+	s.tracker.Broadcast(model.VssResponse(ch, workspaceId, conversationId))
+}
 
-				_, keyExists := chunksByDocId[documentId]
-				if !keyExists { // create key
-					chunksByDocId[documentId] = &model.ChunkValues{}
-				}
+func (s Session) SearchOrg(m model.OrgSearch) {
+	if s.token == nil {
+		s.tracker.Broadcast(model.NotAuthorized("no token provided", http.StatusUnauthorized, s.workspaceId, ""))
+		return
+	}
 
-				chunksByDocId[documentId].AddChunk(scoredChunk)
+	claims := s.token.Claims.(*model.ClerkClaims)
+	userId := claims.Subject
 
-				if err != nil {
-					fmt.Printf("loader error: %s", err)
-				}
-			}
-		}
+	workspaces, err := s.handler.accessibleWorkspaces(s.orgId, userId)
+	if err != nil {
+		s.tracker.Broadcast(model.NotAuthorized(err.Error(), http.StatusNotFound, s.workspaceId, userId))
+		return
+	}
 
-		var ch model.ContextHolder
-		for docId, cv := range chunksByDocId {
-			// create a ContextLoader and add to ContextHolder
-			document, _ := s.handler.PG.GetDocument(docId)
-			ch.AddLoader(model.ContextLoaderScored{DocumentId: docId, DocumentName: document.Name, Context: cv})
-		}
-		ch.Query = vssText
+	och, err := s.handler.searchOrg(s.embedder, s.orgId, workspaces, m.Query)
+	check(err)
 
-		// broadcast json to frontend
-		s.tracker.Broadcast(model.VssResponse(ch, workspaceId, conversationId))
-	}
+	s.tracker.Broadcast(model.OrgSearchResponse(och, s.workspaceId, s.conversationId))
 }
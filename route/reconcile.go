@@ -0,0 +1,120 @@
+package route
+
+import (
+	"errors"
+	"time"
+	"vector-ai/model"
+
+	pg "github.com/jackc/pgx/v5"
+)
+
+// orphan points uploaded more recently are left alone, as their upload may still be
+// running in another process, e.g. the CLI
+var orphanGrace = 10 * time.Minute
+
+// Reconcile compares every point in the org collection with the documents table.
+// With repair, orphan points are deleted and documents with missing or mismatched
+// vectors are flagged for reindex.
+func (h Handler) Reconcile(orgId string, repair bool) (model.ReconciliationReport, error) {
+	report := model.ReconciliationReport{
+		OrgID:             orgId,
+		OrphanPoints:      []model.OrphanPoints{},
+		MissingVectors:    []model.VectorMismatch{},
+		MismatchedVectors: []model.VectorMismatch{},
+	}
+
+	counts, err := h.QD.CountPointsByDocument(orgId)
+	if err != nil {
+		return report, err
+	}
+
+	workspaces, err := h.PG.ListWorkspaces(orgId)
+	if err != nil {
+		return report, err
+	}
+
+	// documents by workspaceId, then documentId
+	documents := map[string]map[string]model.Document{}
+	for _, workspace := range workspaces {
+		docs, err := h.PG.ListDocuments(workspace.ID)
+		if err != nil {
+			return report, err
+		}
+
+		documents[workspace.ID] = map[string]model.Document{}
+		for _, doc := range docs {
+			documents[workspace.ID][doc.ID] = doc
+		}
+		report.Documents += len(docs)
+	}
+
+	// points whose workspace or document no longer exists
+	for workspaceId, documentCounts := range counts {
+		for documentId, points := range documentCounts {
+			report.Points += points.Points
+
+			// uploads write their points before the document row
+			recent := time.Since(points.Uploaded) < orphanGrace
+			if _, ok := documents[workspaceId][documentId]; !ok && !recent && !h.TR.Uploading(documentId) {
+				report.OrphanPoints = append(report.OrphanPoints, model.OrphanPoints{WorkspaceID: workspaceId, DocumentID: documentId, Points: points.Points})
+			}
+		}
+	}
+
+	// documents without points, or with a different number than recorded
+	for workspaceId, docs := range documents {
+		for documentId, doc := range docs {
+			points := counts[workspaceId][documentId].Points
+			mismatch := model.VectorMismatch{WorkspaceID: workspaceId, DocumentID: documentId, Name: doc.Name, Expected: doc.Vectors, Actual: points}
+
+			if points == 0 {
+				report.MissingVectors = append(report.MissingVectors, mismatch)
+			} else if points != doc.Vectors {
+				report.MismatchedVectors = append(report.MismatchedVectors, mismatch)
+			}
+		}
+	}
+
+	if !repair {
+		return report, nil
+	}
+
+	if len(report.OrphanPoints) > 0 {
+		report.Snapshot, err = h.autoSnapshot(orgId, "reconcile")
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, orphan := range report.OrphanPoints {
+		// the upload has since saved its document
+		_, err = h.PG.GetDocument(orphan.DocumentID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pg.ErrNoRows) {
+			return report, err
+		}
+
+		_, err = h.QD.DeleteVectorsByDocumentId(orgId, orphan.WorkspaceID, orphan.DocumentID)
+		if err != nil {
+			return report, err
+		}
+
+		err = h.PG.DeleteChunksByDocumentId(orphan.DocumentID)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, mismatch := range append(report.MissingVectors, report.MismatchedVectors...) {
+		err = h.PG.FlagDocumentReindex(mismatch.DocumentID)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	report.Repaired = true
+
+	return report, nil
+}
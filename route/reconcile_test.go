@@ -0,0 +1,78 @@
+package route
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	defer func(grace time.Duration) { orphanGrace = grace }(orphanGrace)
+
+	h, fake, embedder := newTestHandler(t)
+	upload(t, h, embedder, "ws", "kept", []string{"first chunk", "second chunk"}, false)
+	upload(t, h, embedder, "ws", "orphan", []string{"left behind"}, true)
+	upload(t, h, embedder, "ws", "uploading", []string{"not saved yet"}, true)
+	upload(t, h, embedder, "ws", "missing", []string{}, false)
+
+	fake.documents["ws"][0].Vectors = 3 // recorded before a failed re-upload
+
+	h.TR.StartUpload("uploading")
+	defer h.TR.FinishUpload("uploading")
+
+	// just uploaded, possibly by another process
+	report, err := h.Reconcile(testOrg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.OrphanPoints) != 0 {
+		t.Errorf("orphans %v within the grace period", report.OrphanPoints)
+	}
+
+	orphanGrace = 0
+
+	report, err = h.Reconcile(testOrg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Points != 4 || report.Documents != 2 {
+		t.Errorf("counted %d points and %d documents, want 4 and 2", report.Points, report.Documents)
+	}
+	if len(report.OrphanPoints) != 1 || report.OrphanPoints[0].DocumentID != "orphan" {
+		t.Errorf("orphans %v, want only orphan", report.OrphanPoints)
+	}
+	if len(report.MissingVectors) != 1 || report.MissingVectors[0].DocumentID != "missing" {
+		t.Errorf("missing %v, want only missing", report.MissingVectors)
+	}
+	if len(report.MismatchedVectors) != 1 || report.MismatchedVectors[0].DocumentID != "kept" {
+		t.Errorf("mismatched %v, want only kept", report.MismatchedVectors)
+	}
+	if report.Repaired || len(fake.reindexed) > 0 {
+		t.Fatal("a report without repair changed something")
+	}
+
+	report, err = h.Reconcile(testOrg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Repaired {
+		t.Error("repair not reported")
+	}
+
+	counts, err := h.QD.CountPointsByDocument(testOrg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := counts["ws"]["orphan"]; ok {
+		t.Error("orphan points survived repair")
+	}
+	if counts["ws"]["uploading"].Points != 1 {
+		t.Error("repair deleted the points of an upload in progress")
+	}
+
+	slices.Sort(fake.reindexed)
+	if !slices.Equal(fake.reindexed, []string{"kept", "missing"}) {
+		t.Errorf("flagged %v for reindex, want kept and missing", fake.reindexed)
+	}
+}
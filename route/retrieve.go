@@ -0,0 +1,357 @@
+package route
+
+import (
+	"cmp"
+	bg "context"
+	"fmt"
+	"slices"
+	c "vector-ai/constants"
+	"vector-ai/model"
+	"vector-ai/provider"
+	"vector-ai/rerank"
+	"vector-ai/util"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// retrieve runs the workspace's configured search mode (dense, keyword or hybrid),
+// reranks if configured, and returns hits in rank order, limited per document
+func (h Handler) retrieve(embedder *embeddings.EmbedderImpl, llm llms.Model, orgId string, workspaceId string, query string, filter model.VssFilter) ([]model.Hit, error) {
+	return h.retrieveQueries(embedder, llm, orgId, workspaceId, []string{query}, filter)
+}
+
+// retrieveQueries searches along each query and fuses the lists, deduplicating by
+// point id. The fused candidates are reranked once, against the first query, which
+// the others only expand on.
+func (h Handler) retrieveQueries(embedder *embeddings.EmbedderImpl, llm llms.Model, orgId string, workspaceId string, queries []string, filter model.VssFilter) ([]model.Hit, error) {
+
+	// Get configs and create a struct from them
+	configs, err := h.PG.ListWorkspaceConfigs(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	options := util.MarshalVssOptions(configs)
+	reranking := options.RerankMode != rerank.ModeNone && options.RerankCandidates > 0
+
+	// widen the search so there are enough candidates to rerank, even when they
+	// come from only a few documents
+	candidates := options.RerankCandidates
+	wide := options
+	if reranking {
+		wide.VssDocumentLimit = max(options.VssDocumentLimit, candidates)
+		if options.VssDocumentLimit > 0 {
+			perDocument := (candidates + options.VssDocumentLimit - 1) / options.VssDocumentLimit
+			wide.VssChunkLimit = max(options.VssChunkLimit, perDocument)
+		}
+	}
+
+	lists := [][]model.Hit{}
+	for _, query := range queries {
+		hits, err := h.search(embedder, orgId, workspaceId, query, filter, wide)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, hits)
+	}
+
+	hits := lists[0]
+	if len(lists) > 1 {
+		hits = util.ReciprocalRankFusion(lists, util.RrfK)
+		if !reranking {
+			hits = util.LimitPerDocument(hits, options.VssDocumentLimit, options.VssChunkLimit)
+		}
+	}
+
+	if !reranking {
+		return hits, nil
+	}
+
+	hits = hits[:min(len(hits), int(candidates))]
+
+	// a failed rerank keeps the fused order, which still answers the query
+	reranked, err := rerank.New(options.RerankMode, llm).Rerank(bg.Background(), queries[0], hits)
+	if err == nil {
+		hits = reranked
+	} else {
+		fmt.Println("Rerank failed, keeping the fused order:", err)
+	}
+
+	// the widened search may return more documents and chunks than configured
+	hits = util.LimitPerDocument(hits, options.VssDocumentLimit, options.VssChunkLimit)
+
+	if options.RerankKeep > 0 {
+		hits = hits[:min(len(hits), int(options.RerankKeep))]
+	}
+
+	return hits, nil
+}
+
+// workspaceLLM returns the chat model a workspace is configured for, and its name
+func (h Handler) workspaceLLM(workspaceId string) (llms.Model, string, error) {
+	configs, err := h.PG.ListWorkspaceConfigs(workspaceId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	options := util.MarshalVssOptions(configs)
+	return provider.New(options.LlmProvider, options.LlmModel)
+}
+
+// search queries the dense and/or keyword index and fuses the results
+func (h Handler) search(embedder *embeddings.EmbedderImpl, orgId string, workspaceId string, query string, filter model.VssFilter, limits model.VssOptions) ([]model.Hit, error) {
+	lists := [][]model.Hit{}
+
+	// fetch extra chunks per document as MMR candidates
+	options := limits
+	if limits.MmrLambda > 0 {
+		options.VssChunkLimit = limits.VssChunkLimit * util.MmrFetchFactor
+	}
+
+	if options.VssMode != model.VssModeKeyword {
+		// Vectorizing query body...
+		floats, err := embedder.EmbedQuery(bg.Background(), query)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = h.QD.GetCollection(orgId)
+		if err == nil {
+			denseHits, err := h.QD.Vss(floats, orgId, workspaceId, options, filter) // [0.1, 0.6, 0.5...]
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, denseHits)
+		}
+	}
+
+	if options.VssMode != model.VssModeDense {
+		limit := options.VssDocumentLimit * options.VssChunkLimit
+		keywordHits, err := h.PG.SearchChunks(workspaceId, query, filter, limit)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, keywordHits)
+	}
+
+	var hits []model.Hit
+	if len(lists) == 1 {
+		hits = lists[0]
+	} else {
+		hits = util.ReciprocalRankFusion(lists, util.RrfK)
+	}
+
+	if limits.MmrLambda > 0 {
+		lambda := float32(limits.MmrLambda) / 100
+		return util.MaximalMarginalRelevance(hits, lambda, limits.VssDocumentLimit, limits.VssChunkLimit), nil
+	}
+
+	return util.LimitPerDocument(hits, limits.VssDocumentLimit, limits.VssChunkLimit), nil
+}
+
+// searchOrg runs a dense search over every given workspace of the org and groups
+// the hits by workspace, then document
+func (h Handler) searchOrg(embedder *embeddings.EmbedderImpl, orgId string, workspaces []model.Workspace, query string) (model.OrgContextHolder, error) {
+	och := model.OrgContextHolder{Results: []model.WorkspaceContext{}, Query: query}
+
+	_, err := h.QD.GetCollection(orgId)
+	if err != nil || len(workspaces) == 0 {
+		return och, nil // nothing uploaded yet
+	}
+
+	workspaceIds := []string{}
+	workspaceNames := map[string]string{}
+	for _, workspace := range workspaces {
+		workspaceIds = append(workspaceIds, workspace.ID)
+		workspaceNames[workspace.ID] = workspace.Name
+	}
+
+	floats, err := embedder.EmbedQuery(bg.Background(), query)
+	if err != nil {
+		return och, err
+	}
+
+	options := model.VssOptions{VssDocumentLimit: c.OrgSearchDocumentLimit, VssChunkLimit: c.OrgSearchChunkLimit}
+	hits, err := h.QD.OrgVss(floats, orgId, workspaceIds, options)
+	if err != nil {
+		return och, err
+	}
+
+	hits = util.LimitPerDocument(hits, options.VssDocumentLimit, options.VssChunkLimit)
+
+	// keep workspaces in order of their best hit
+	hitWorkspaceIds := []string{}
+	hitsByWorkspaceId := map[string][]model.Hit{}
+	for _, hit := range hits {
+		if _, keyExists := hitsByWorkspaceId[hit.WorkspaceID]; !keyExists {
+			hitWorkspaceIds = append(hitWorkspaceIds, hit.WorkspaceID)
+		}
+		hitsByWorkspaceId[hit.WorkspaceID] = append(hitsByWorkspaceId[hit.WorkspaceID], hit)
+	}
+
+	for _, workspaceId := range hitWorkspaceIds {
+		ch := h.contextHolder(hitsByWorkspaceId[workspaceId], query)
+		och.Results = append(och.Results, model.WorkspaceContext{
+			WorkspaceID:   workspaceId,
+			WorkspaceName: workspaceNames[workspaceId],
+			Results:       ch.Results,
+		})
+	}
+
+	return och, nil
+}
+
+// accessibleWorkspaces lists the org's workspaces if the user belongs to the org
+func (h Handler) accessibleWorkspaces(orgId string, userId string) ([]model.Workspace, error) {
+	_, err := h.PG.GetUserOrgAssociation(orgId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.PG.ListWorkspaces(orgId)
+}
+
+// groups hits by document, keeping documents in order of their best hit
+func (h Handler) contextHolder(hits []model.Hit, query string) model.ContextHolder {
+	var ch model.ContextHolder
+
+	docIds := []string{}
+	chunksByDocId := map[string]*model.ChunkValues{}
+
+	for _, hit := range hits {
+		_, keyExists := chunksByDocId[hit.DocumentID]
+		if !keyExists { // create key
+			chunksByDocId[hit.DocumentID] = &model.ChunkValues{}
+			docIds = append(docIds, hit.DocumentID)
+		}
+
+		chunksByDocId[hit.DocumentID].AddChunk(model.ScoredChunk{ID: hit.ID, Value: hit.Value, Score: hit.Score, RerankScore: hit.RerankScore})
+	}
+
+	// create a ContextLoader and add to ContextHolder
+	for _, docId := range docIds {
+		document, _ := h.PG.GetDocument(docId)
+		ch.AddLoader(model.ContextLoaderScored{DocumentId: docId, DocumentName: document.Name, Context: chunksByDocId[docId]})
+	}
+	ch.Query = query
+
+	return ch
+}
+
+// expandHits merges each hit with its ±adjacentRange neighbours into one contiguous
+// passage per document window. Overlapping windows are merged and keep the id and
+// scores of their best hit.
+func (h Handler) expandHits(orgId string, hits []model.Hit, adjacentRange int64) ([]model.Hit, error) {
+	type window struct {
+		start int64
+		end   int64
+		best  model.Hit
+	}
+
+	docIds := []string{}
+	windowsByDocId := map[string][]window{}
+
+	for _, hit := range hits {
+		if _, keyExists := windowsByDocId[hit.DocumentID]; !keyExists {
+			docIds = append(docIds, hit.DocumentID)
+		}
+		w := window{start: max(hit.Index-adjacentRange, 0), end: hit.Index + adjacentRange, best: hit}
+		windowsByDocId[hit.DocumentID] = append(windowsByDocId[hit.DocumentID], w)
+	}
+
+	expanded := []model.Hit{}
+
+	for _, docId := range docIds {
+		windows := windowsByDocId[docId]
+		slices.SortFunc(windows, func(a window, b window) int {
+			return cmp.Compare(a.start, b.start)
+		})
+
+		// merge overlapping and touching windows
+		merged := []window{windows[0]}
+		for _, w := range windows[1:] {
+			last := &merged[len(merged)-1]
+			if w.start <= last.end+1 {
+				last.end = max(last.end, w.end)
+				if w.best.Score > last.best.Score {
+					last.best = w.best
+				}
+			} else {
+				merged = append(merged, w)
+			}
+		}
+
+		indeces := []int64{}
+		for _, w := range merged {
+			for i := w.start; i <= w.end; i++ {
+				indeces = append(indeces, i)
+			}
+		}
+
+		points, err := h.QD.GetPointsByIndeces(orgId, docId, indeces)
+		if err != nil {
+			return nil, err
+		}
+
+		chunkByIndex := map[int64]string{}
+		for _, point := range points {
+			chunkByIndex[point.Index] = point.Text
+		}
+
+		for _, w := range merged {
+			chunks := []string{}
+			for i := w.start; i <= w.end; i++ {
+				if chunk, ok := chunkByIndex[i]; ok {
+					chunks = append(chunks, chunk)
+				}
+			}
+
+			passage := w.best
+			passage.Value = util.JoinChunks(chunks)
+			passage.Vector = nil
+			expanded = append(expanded, passage)
+		}
+	}
+
+	return expanded, nil
+}
+
+// backfillChunks mirrors an org's points into the chunks table, skipping points of
+// documents that no longer exist
+func (h Handler) backfillChunks(orgId string) (int64, error) {
+	workspaces, err := h.PG.ListWorkspaces(orgId)
+	if err != nil {
+		return 0, err
+	}
+
+	documentIds := map[string]bool{}
+	for _, workspace := range workspaces {
+		docs, err := h.PG.ListDocuments(workspace.ID)
+		if err != nil {
+			return 0, err
+		}
+		for _, doc := range docs {
+			documentIds[doc.ID] = true
+		}
+	}
+
+	var inserted int64
+	it := h.QD.Scroll(orgId, "", false)
+	for it.Next() {
+		chunks := []model.Chunk{}
+		for _, chunk := range it.Page() {
+			if documentIds[chunk.DocumentID] {
+				chunks = append(chunks, chunk)
+			}
+		}
+
+		count, err := h.PG.BackfillChunks(chunks)
+		inserted += count
+		if err != nil {
+			return inserted, err
+		}
+	}
+
+	return inserted, it.Err()
+}
@@ -0,0 +1,134 @@
+package route
+
+import (
+	"slices"
+	"testing"
+	"vector-ai/model"
+	"vector-ai/rerank"
+)
+
+func documentIds(hits []model.Hit) []string {
+	ids := []string{}
+	for _, hit := range hits {
+		ids = append(ids, hit.DocumentID)
+	}
+	return ids
+}
+
+func TestRetrieve(t *testing.T) {
+	h, fake, embedder := newTestHandler(t)
+	upload(t, h, embedder, "ws", "solar", []string{"solar panels convert sunlight", "solar panels on the roof", "panels need cleaning"}, false)
+	upload(t, h, embedder, "ws", "baking", []string{"bread needs flour and yeast", "bake the bread for an hour"}, false)
+	upload(t, h, embedder, "ws", "gardening", []string{"water the garden in the morning"}, false)
+	upload(t, h, embedder, "other", "elsewhere", []string{"solar panels convert sunlight"}, false)
+
+	tests := []struct {
+		name          string
+		query         string
+		filter        model.VssFilter
+		documentLimit int64
+		chunkLimit    int64
+		want          []string
+	}{
+		{
+			name:          "best document first, chunk limit held",
+			query:         "solar panels",
+			documentLimit: 1,
+			chunkLimit:    2,
+			want:          []string{"solar", "solar"},
+		},
+		{
+			name:          "document limit held",
+			query:         "bread",
+			documentLimit: 1,
+			chunkLimit:    5,
+			want:          []string{"baking", "baking"},
+		},
+		{
+			name:          "filtered to a document",
+			query:         "solar panels",
+			filter:        model.VssFilter{DocumentIDs: []string{"baking"}},
+			documentLimit: 3,
+			chunkLimit:    1,
+			want:          []string{"baking"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.config("vssDocumentLimit", tt.documentLimit)
+			fake.config("vssChunkLimit", tt.chunkLimit)
+
+			hits, err := h.retrieve(h.EM, nil, testOrg, "ws", tt.query, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := documentIds(hits); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrieveQueriesRerankOnce(t *testing.T) {
+	// a second rerank call would get no score array and fail
+	t.Setenv("LLM_FAKE_RESPONSES", "[1, 9, 5]\n---\nno scores")
+
+	h, fake, embedder := newTestHandler(t)
+	upload(t, h, embedder, "ws", "solar", []string{"solar panels convert sunlight"}, false)
+	upload(t, h, embedder, "ws", "wind", []string{"wind turbines convert wind"}, false)
+	upload(t, h, embedder, "ws", "water", []string{"water wheels convert rivers"}, false)
+
+	fake.config("rerankMode", rerank.ModeLLM)
+	fake.config("rerankCandidates", 3)
+	fake.config("rerankKeep", 2)
+
+	llm, _, err := h.workspaceLLM("ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := h.retrieveQueries(h.EM, llm, testOrg, "ws", []string{"solar panels", "wind turbines"}, model.VssFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want rerankKeep 2", len(hits))
+	}
+	if hits[0].RerankScore < hits[1].RerankScore {
+		t.Errorf("hits not in rerank order: %v then %v", hits[0].RerankScore, hits[1].RerankScore)
+	}
+	if hits[0].RerankScore != 0.9 {
+		t.Errorf("best rerank score %v, want 0.9", hits[0].RerankScore)
+	}
+}
+
+func TestRetrieveRerankFallback(t *testing.T) {
+	t.Setenv("LLM_FAKE_RESPONSES", "no scores")
+
+	h, fake, embedder := newTestHandler(t)
+	upload(t, h, embedder, "ws", "solar", []string{"solar panels convert sunlight", "solar panels on the roof", "solar panels need cleaning"}, false)
+	upload(t, h, embedder, "ws", "wind", []string{"wind turbines convert wind"}, false)
+
+	fake.config("vssDocumentLimit", 1)
+	fake.config("vssChunkLimit", 2)
+	fake.config("rerankMode", rerank.ModeLLM)
+	fake.config("rerankCandidates", 4)
+
+	llm, _, err := h.workspaceLLM("ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := h.retrieve(h.EM, llm, testOrg, "ws", "solar panels", model.VssFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fused order, capped back to one document of two chunks
+	if got := documentIds(hits); !slices.Equal(got, []string{"solar", "solar"}) {
+		t.Errorf("got %v, want the two best solar chunks", got)
+	}
+}
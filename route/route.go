@@ -1,15 +1,23 @@
 package route
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"slices"
+	"strconv"
 	"time"
 
+	c "vector-ai/constants"
 	"vector-ai/drive"
 	"vector-ai/model"
 	pgx "vector-ai/postgres"
+	"vector-ai/provider"
 	"vector-ai/qdrant"
+	"vector-ai/rerank"
 	"vector-ai/util"
 
 	"github.com/clerkinc/clerk-sdk-go/clerk"
@@ -126,6 +134,148 @@ func (h Handler) ClearExpiredInvites(res *goyave.Response, req *goyave.Request)
 	}
 }
 
+//
+// Qdrant Admin
+//
+
+// one-off: adds payload indexes to collections created before they were provisioned
+func (h Handler) IndexCollections(res *goyave.Response, req *goyave.Request) {
+	orgs, err := h.PG.ListOrgs()
+	check(err)
+
+	indexed := []string{}
+	for _, org := range orgs {
+		_, qErr := h.QD.GetCollection(org.ID)
+		if qErr != nil {
+			continue // org has no collection yet
+		}
+
+		err = h.QD.CreatePayloadIndexes(org.ID)
+		if err != nil {
+			break
+		}
+		indexed = append(indexed, org.ID)
+	}
+
+	if err == nil {
+		res.JSON(http.StatusOK, model.HTTPResponse{Message: fmt.Sprintf("Indexed %d collections", len(indexed))})
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// one-off: mirrors the text of points uploaded before keyword search into the chunks
+// table, so keyword and hybrid vssMode find them
+func (h Handler) BackfillChunks(res *goyave.Response, req *goyave.Request) {
+	orgs, err := h.PG.ListOrgs()
+	check(err)
+
+	var inserted int64
+	for _, org := range orgs {
+		_, qErr := h.QD.GetCollection(org.ID)
+		if qErr != nil {
+			continue // org has no collection yet
+		}
+
+		var count int64
+		count, err = h.backfillChunks(org.ID)
+		inserted += count
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		res.JSON(http.StatusOK, model.HTTPResponse{Message: fmt.Sprintf("Backfilled %d chunks", inserted)})
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// reports points and documents that disagree, PUT also repairs them
+func (h Handler) ReconcileOrg(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	repair := req.Method() == http.MethodPut
+
+	report, err := h.Reconcile(orgId, repair)
+
+	if err == nil {
+		res.JSON(http.StatusOK, report)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+func (h Handler) CreateSnapshot(res *goyave.Response, req *goyave.Request) {
+	result, err := h.QD.CreateSnapshot(req.Params["orgId"])
+
+	if err == nil {
+		res.JSON(http.StatusCreated, result)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+func (h Handler) ListSnapshots(res *goyave.Response, req *goyave.Request) {
+	results, err := h.QD.ListSnapshots(req.Params["orgId"])
+
+	if err == nil {
+		res.JSON(http.StatusOK, results)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// snapshots taken automatically before destructive operations, including org deletion
+func (h Handler) ListAutoSnapshots(res *goyave.Response, req *goyave.Request) {
+	results, err := h.PG.ListAutoSnapshots(req.Params["orgId"])
+
+	if err == nil {
+		res.JSON(http.StatusOK, results)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+func (h Handler) DownloadSnapshot(res *goyave.Response, req *goyave.Request) {
+	snapshotName := req.Params["snapshotName"]
+
+	snapshot, err := h.QD.DownloadSnapshot(req.Params["orgId"], snapshotName)
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+	defer snapshot.Close()
+
+	res.Header().Set("Content-Type", "application/octet-stream")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshotName))
+	res.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(res, snapshot)
+	check(err)
+}
+
+func (h Handler) RestoreSnapshot(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	snapshotName := req.Params["snapshotName"]
+
+	err := h.QD.RestoreSnapshot(orgId, snapshotName)
+
+	if err == nil {
+		res.JSON(http.StatusOK, model.HTTPResponse{Message: fmt.Sprintf("Restored org %s from %s", orgId, snapshotName)})
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
 //
 // User Routes
 //
@@ -387,6 +537,36 @@ func (h Handler) UpdateWorkspaceConfig(res *goyave.Response, req *goyave.Request
 
 	// value := int64(req.Integer("value"))
 
+	if propertyName == "llmProvider" && value == provider.ProviderFake && !provider.FakeAllowed() {
+		res.Status(http.StatusUnprocessableEntity)
+		res.Error(errors.New("the fake llm provider needs LLM_FAKE_RESPONSES or the memory store"))
+		return
+	}
+
+	if propertyName == "vssScoreThreshold" && (value < 0 || value > c.VssScoreThresholdMax) {
+		res.Status(http.StatusUnprocessableEntity)
+		res.Error(fmt.Errorf("vssScoreThreshold must be between 0 and %d", c.VssScoreThresholdMax))
+		return
+	}
+
+	// the provider has to offer the model, whichever of the two changes
+	if propertyName == "llmProvider" || propertyName == "llmModel" {
+		other := map[string]string{"llmProvider": "llmModel", "llmModel": "llmProvider"}[propertyName]
+		config, err := h.PG.GetWorkspaceConfig(workspaceId, other)
+		if err != nil {
+			res.Status(http.StatusInternalServerError)
+			res.Error(err)
+			return
+		}
+
+		selection := map[string]int64{propertyName: value, other: config.Value}
+		if err := provider.ValidModel(uint32(selection["llmProvider"]), uint32(selection["llmModel"])); err != nil {
+			res.Status(http.StatusUnprocessableEntity)
+			res.Error(err)
+			return
+		}
+	}
+
 	result, err := h.PG.UpdateWorkspaceConfig(workspaceId, propertyName, value)
 
 	if err == nil {
@@ -483,6 +663,15 @@ func (h Handler) GetOrg(res *goyave.Response, req *goyave.Request) {
 func (h Handler) CreateOrg(res *goyave.Response, req *goyave.Request) {
 
 	orgName := req.String("name")
+	distance := c.Distance
+	if req.Has("distance") {
+		distance = req.String("distance")
+	}
+	if _, err := qdrant.Distance(distance); err != nil {
+		res.Status(http.StatusUnprocessableEntity)
+		res.Error(err)
+		return
+	}
 	claims := req.Extra["jwt_claims"].(*model.ClerkClaims)
 	userId := claims.Subject
 
@@ -507,7 +696,7 @@ func (h Handler) CreateOrg(res *goyave.Response, req *goyave.Request) {
 	_, qErr := h.QD.GetCollection(orgId)
 
 	if qErr != nil {
-		h.QD.CreateCollection(orgId, uint64(1536))
+		h.QD.CreateCollection(orgId, uint64(c.VectorSize), distance)
 	}
 
 	if err == nil {
@@ -544,6 +733,13 @@ func (h Handler) UpdateOrg(res *goyave.Response, req *goyave.Request) {
 func (h Handler) DeleteOrg(res *goyave.Response, req *goyave.Request) {
 	orgId := req.Params["orgId"]
 
+	snapshotName, err := h.autoSnapshot(orgId, "deleteOrg")
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
 	// Get all workspaces belonging to org
 	workspaces, err := h.PG.ListWorkspaces(orgId)
 	check(err)
@@ -571,8 +767,36 @@ func (h Handler) DeleteOrg(res *goyave.Response, req *goyave.Request) {
 	// Delete qdrant collection
 	err = h.QD.DeleteCollection(orgId)
 
+	message := fmt.Sprintf("Deleted org %s", orgId)
+	if snapshotName != "" {
+		message += fmt.Sprintf(", snapshot %s", snapshotName)
+	}
+
+	if err == nil {
+		res.JSON(http.StatusOK, model.HTTPResponse{Message: message})
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// searches every workspace in the org the caller can access
+func (h Handler) SearchOrg(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	query := req.String("query")
+	claims := req.Extra["jwt_claims"].(*model.ClerkClaims)
+
+	workspaces, err := h.accessibleWorkspaces(orgId, claims.Subject)
+	if err != nil {
+		res.Status(http.StatusNotFound)
+		res.Error(err)
+		return
+	}
+
+	result, err := h.searchOrg(h.EM, orgId, workspaces, query)
+
 	if err == nil {
-		res.JSON(http.StatusOK, model.HTTPResponse{Message: fmt.Sprintf("Deleted org %s", orgId)})
+		res.JSON(http.StatusOK, result)
 	} else {
 		res.Status(http.StatusInternalServerError)
 		res.Error(err)
@@ -753,6 +977,51 @@ func (h Handler) CreateWorkspace(res *goyave.Response, req *goyave.Request) {
 	_, err = h.PG.CreateWorkspaceConfig("9a6b5324-1bee-46c5-862d-2289299b89d1", workspace.ID, "vssChunkLimit", 2)
 	check(err)
 
+	_, err = h.PG.CreateWorkspaceConfig("c1d7e0a4-58b2-4f0e-9d3c-7a41e6b2f915", workspace.ID, "vssMode", model.VssModeDense)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("4b0f6d2e-9a13-4c7b-b8e5-0d3f92a6c471", workspace.ID, "vssScoreThreshold", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("8e2a4c61-07d3-4b9f-a1c5-63f0d8b2e794", workspace.ID, "rerankMode", rerank.ModeNone)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("d5f93b17-6c2e-48a0-9e71-2b4c8f0a6d35", workspace.ID, "rerankCandidates", 40)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("27c8e6a9-b450-4d1f-8f3b-9a6e1c5d0b82", workspace.ID, "rerankKeep", 10)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("f0b3d8c2-5e71-4a96-b2d4-8c1e7a9f3065", workspace.ID, "mmrLambda", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("a93e5f07-2d8b-4c61-b7f4-5e0c9d1a8b26", workspace.ID, "contextExpansion", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("6d1c8a3f-e94b-4702-a5d8-b37f0c2e9164", workspace.ID, "multiQuery", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("e4a7b2d9-1f36-48c5-9b0e-72d5c8f1a3b0", workspace.ID, "hydeQuery", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("3f8b1e6c-a2d4-4c97-8e05-b6d1f4a9c723", workspace.ID, "duplicateMode", model.DuplicateModeWarn)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("1b7e9c4d-3a52-4f86-b0d1-e8a6c2f5947b", workspace.ID, "historyTurns", 5)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("5c2d8f1a-7e94-4b03-a6c5-d9f1b3e8027a", workspace.ID, "historyTokens", 2000)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("b2e4d7a1-6f83-4c59-9a0e-3d1c8b5f7e26", workspace.ID, "llmProvider", int64(provider.Default()))
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("e7a3c9f5-0d21-4b86-b4f7-6a8e2c1d9b53", workspace.ID, "llmModel", 0)
+	check(err)
+
+	_, err = h.PG.CreateWorkspaceConfig("4d9f1b6e-c837-4a20-8e5b-f2a7d0c3e619", workspace.ID, "llmTemperature", 0)
+	check(err)
+
 	templates := req.Data["templates"].([]string)
 	timestamp := time.Now().Format(time.RFC3339)
 
@@ -760,7 +1029,7 @@ func (h Handler) CreateWorkspace(res *goyave.Response, req *goyave.Request) {
 	for _, templateId := range templates {
 		template, err := h.PG.GetTemplate(templateId)
 		check(err)
-		h.PG.CreateTemplate(orgId, workspace.ID, template.Name, template.Text, userId, authorName, timestamp)
+		h.PG.CreateTemplate(orgId, workspace.ID, template.Name, template.Text, template.Variables, userId, authorName, timestamp)
 	}
 
 	if err == nil {
@@ -790,8 +1059,15 @@ func (h Handler) ClearWorkspace(res *goyave.Response, req *goyave.Request) {
 	orgId := req.Params["orgId"]
 	workspaceId := req.Params["workspaceId"]
 
+	_, err := h.autoSnapshot(orgId, "clearWorkspace")
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
 	// Clear documents from postgres
-	err := h.PG.ClearDocuments(workspaceId)
+	err = h.PG.ClearDocuments(workspaceId)
 
 	if err != nil {
 		res.Status(http.StatusInternalServerError)
@@ -802,6 +1078,14 @@ func (h Handler) ClearWorkspace(res *goyave.Response, req *goyave.Request) {
 	pointsDeleted, err := h.QD.DeleteVectorsByWorkspaceId(orgId, workspaceId)
 	message := fmt.Sprintf("Cleared workspace, deleting '%d' points", pointsDeleted)
 
+	if err == nil {
+		err = h.PG.ClearChunks(workspaceId)
+	}
+
+	if err == nil {
+		err = h.PG.ClearTopics(workspaceId)
+	}
+
 	if err == nil {
 		res.JSON(http.StatusOK, message)
 	} else {
@@ -813,7 +1097,15 @@ func (h Handler) ClearWorkspace(res *goyave.Response, req *goyave.Request) {
 func (h Handler) DeleteWorkspace(res *goyave.Response, req *goyave.Request) {
 	orgId := req.Params["orgId"]
 	workspaceId := req.Params["workspaceId"]
-	err := h.PG.DeleteWorkspace(workspaceId)
+
+	_, err := h.autoSnapshot(orgId, "deleteWorkspace")
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
+	err = h.PG.DeleteWorkspace(workspaceId)
 	if err != nil {
 		res.Status(http.StatusInternalServerError)
 		res.Error(err)
@@ -822,6 +1114,12 @@ func (h Handler) DeleteWorkspace(res *goyave.Response, req *goyave.Request) {
 	_, err = h.QD.DeleteVectorsByWorkspaceId(orgId, workspaceId)
 	check(err)
 
+	err = h.PG.ClearChunks(workspaceId)
+	check(err)
+
+	err = h.PG.ClearTopics(workspaceId)
+	check(err)
+
 	subscription, err := h.PG.GetOrgStripeSubscriptionAssociationByOrgId(orgId)
 	if subscription.Active {
 		record, err := h.createUsageEvent(orgId)
@@ -837,6 +1135,40 @@ func (h Handler) DeleteWorkspace(res *goyave.Response, req *goyave.Request) {
 	}
 }
 
+// streams every point of the workspace as newline-delimited JSON, a page at a time
+func (h Handler) ExportWorkspace(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	workspaceId := req.Params["workspaceId"]
+
+	f, err := flusher(res)
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/x-ndjson")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", workspaceId+".ndjson"))
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+
+	scroller := h.QD.Scroll(orgId, workspaceId, true)
+	for scroller.Next() {
+		for _, chunk := range scroller.Page() {
+			err := encoder.Encode(chunk)
+			if err != nil {
+				check(err)
+				return
+			}
+		}
+		f.Flush()
+	}
+
+	// headers are already sent, so a failed scroll can only be logged
+	check(scroller.Err())
+}
+
 //
 // Conversations
 //
@@ -926,6 +1258,9 @@ func (h Handler) DeleteConversation(res *goyave.Response, req *goyave.Request) {
 
 func (h Handler) ListMessages(res *goyave.Response, req *goyave.Request) {
 	results, err := h.PG.ListMessages(req.Params["conversationId"])
+	if err == nil {
+		results, err = h.withSpans(req.Params["orgId"], req.Params["conversationId"], results)
+	}
 
 	if err == nil {
 		res.JSON(http.StatusOK, results)
@@ -937,6 +1272,9 @@ func (h Handler) ListMessages(res *goyave.Response, req *goyave.Request) {
 
 func (h Handler) GetMessage(res *goyave.Response, req *goyave.Request) {
 	result, err := h.PG.GetMessage(req.Params["messageId"])
+	if err == nil && result.AuthorType == "AI" {
+		result.Spans, err = h.answerSpans(req.Params["orgId"], result)
+	}
 
 	if err == nil {
 		res.JSON(http.StatusOK, result)
@@ -998,6 +1336,9 @@ func (h Handler) DeleteDocument(res *goyave.Response, req *goyave.Request) {
 		return
 	}
 
+	err = h.PG.DeleteChunksByDocumentId(documentId)
+	check(err)
+
 	// TODO: develop strategy to properly desync route
 	err = h.PG.DeleteDriveDocumentSyncByDocumentId(documentId)
 	check(err)
@@ -1021,11 +1362,122 @@ func (h Handler) DeleteDocument(res *goyave.Response, req *goyave.Request) {
 	}
 }
 
+// documents of the workspace closest to the given one, with their best matching chunks
+func (h Handler) ListSimilarDocuments(res *goyave.Response, req *goyave.Request) {
+	results, err := h.similarDocuments(req.Params["orgId"], req.Params["workspaceId"], req.Params["documentId"])
+
+	if err == nil {
+		res.JSON(http.StatusOK, results)
+	} else if errors.Is(err, errDocumentNotFound) {
+		res.Status(http.StatusNotFound)
+		res.Error(err)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// stored topics of the workspace, with the state of its latest clustering run
+func (h Handler) ListTopics(res *goyave.Response, req *goyave.Request) {
+	workspaceId := req.Params["workspaceId"]
+	topics, err := h.PG.ListTopics(workspaceId)
+
+	if err == nil {
+		res.JSON(http.StatusOK, model.Topics{Topics: topics, Job: h.TR.TopicJob(workspaceId)})
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+// starts clustering in the background, topics are replaced once every title is generated.
+// One run per workspace at a time, its state is polled with ListTopics.
+func (h Handler) ClusterWorkspaceTopics(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	workspaceId := req.Params["workspaceId"]
+
+	if !h.TR.StartTopicJob(workspaceId) {
+		res.Status(http.StatusConflict)
+		res.Error(fmt.Errorf("workspace %s is already being clustered", workspaceId))
+		return
+	}
+
+	go func() {
+		topics, err := h.ClusterTopics(orgId, workspaceId)
+		h.TR.FinishTopicJob(workspaceId, len(topics), check(err))
+	}()
+
+	res.JSON(http.StatusAccepted, model.HTTPResponse{Message: fmt.Sprintf("Clustering workspace %s", workspaceId)})
+}
+
+// documents of the workspace with identical or mostly overlapping content
+func (h Handler) ListDuplicates(res *goyave.Response, req *goyave.Request) {
+	report, err := h.DuplicateReport(req.Params["orgId"], req.Params["workspaceId"])
+
+	if err == nil {
+		res.JSON(http.StatusOK, report)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+//
+// Usage
+//
+
+// LLM and embedding tokens spent by the org between the from and to dates
+func (h Handler) GetOrgUsage(res *goyave.Response, req *goyave.Request) {
+	h.respondUsage(res, req, "", "")
+}
+
+func (h Handler) GetWorkspaceUsage(res *goyave.Response, req *goyave.Request) {
+	h.respondUsage(res, req, req.Params["workspaceId"], "")
+}
+
+func (h Handler) GetUserUsage(res *goyave.Response, req *goyave.Request) {
+	h.respondUsage(res, req, "", req.Params["userId"])
+}
+
+func (h Handler) respondUsage(res *goyave.Response, req *goyave.Request, workspaceId string, userId string) {
+	var from, to time.Time
+	if req.Has("from") {
+		from = req.Date("from")
+	}
+	if req.Has("to") {
+		to = req.Date("to")
+	}
+
+	interval := "day"
+	if req.Has("interval") {
+		interval = req.String("interval")
+	}
+
+	filter, err := usageFilter(req.Params["orgId"], from, to)
+	if err != nil {
+		res.Status(http.StatusBadRequest)
+		res.Error(err)
+		return
+	}
+	filter.WorkspaceID = workspaceId
+	filter.UserID = userId
+
+	report, err := h.usageReport(filter, interval)
+
+	if err == nil {
+		res.JSON(http.StatusOK, report)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
 //
 // Context
 //
 
 func (h Handler) ListContextsByDocument(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
 	workspaceId := req.Params["workspaceId"]
 	documentId := req.Params["documentId"]
 	results, err := h.PG.ListContextsByDocumentId(documentId) // []Context{id, documentId, messageId, pointId}
@@ -1036,17 +1488,12 @@ func (h Handler) ListContextsByDocument(res *goyave.Response, req *goyave.Reques
 		ids = append(ids, context.PointID)
 	}
 
-	points, err := h.QD.GetPointsByUuid(workspaceId, ids)
+	points, err := h.QD.GetPointsByUuid(orgId, ids)
 	check(err)
 
 	contexts := []model.ConsumableContext{}
 	for _, point := range points {
-		pointId := point.Id.GetUuid()
-		payload := point.GetPayload()
-		documentId := payload["documentId"].GetStringValue()
-		text := payload["chunk"].GetStringValue()
-
-		cc := model.ConsumableContext{ID: pointId, WorkspaceID: workspaceId, DocumentID: documentId, Text: text}
+		cc := model.ConsumableContext{ID: point.ID, WorkspaceID: workspaceId, DocumentID: point.DocumentID, Text: point.Text}
 		contexts = append(contexts, cc)
 	}
 
@@ -1059,6 +1506,7 @@ func (h Handler) ListContextsByDocument(res *goyave.Response, req *goyave.Reques
 }
 
 func (h Handler) ListContextsByMessage(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
 	workspaceId := req.Params["workspaceId"]
 	messageId := req.Params["messageId"]
 	results, err := h.PG.ListContextsByMessageId(messageId) // []Context{id, documentId, messageId, pointId}
@@ -1069,17 +1517,12 @@ func (h Handler) ListContextsByMessage(res *goyave.Response, req *goyave.Request
 		ids = append(ids, context.PointID)
 	}
 
-	points, err := h.QD.GetPointsByUuid(workspaceId, ids)
+	points, err := h.QD.GetPointsByUuid(orgId, ids)
 	check(err)
 
 	contexts := []model.ConsumableContext{}
 	for _, point := range points {
-		pointId := point.Id.GetUuid()
-		payload := point.GetPayload()
-		documentId := payload["documentId"].GetStringValue()
-		text := payload["chunk"].GetStringValue()
-
-		cc := model.ConsumableContext{ID: pointId, WorkspaceID: workspaceId, DocumentID: documentId, Text: text}
+		cc := model.ConsumableContext{ID: point.ID, WorkspaceID: workspaceId, DocumentID: point.DocumentID, Text: point.Text}
 		contexts = append(contexts, cc)
 	}
 
@@ -1104,8 +1547,7 @@ func (h Handler) ListChunks(res *goyave.Response, req *goyave.Request) {
 	check(err)
 
 	// extract document index from point
-	payload := point.GetPayload()
-	index := payload["index"].GetIntegerValue()
+	index := point.Index
 
 	// Get adjacent indeces and return all
 	indeces := util.GetAdjacentIndeces(index, adjacentRange)
@@ -1114,12 +1556,7 @@ func (h Handler) ListChunks(res *goyave.Response, req *goyave.Request) {
 
 	chunks := []model.ConsumableContext{}
 	for _, point := range points {
-		pointId := point.Id.GetUuid()
-		payload := point.GetPayload()
-		documentId := payload["documentId"].GetStringValue()
-		text := payload["chunk"].GetStringValue()
-
-		cc := model.ConsumableContext{ID: pointId, WorkspaceID: workspaceId, DocumentID: documentId, Text: text}
+		cc := model.ConsumableContext{ID: point.ID, WorkspaceID: workspaceId, DocumentID: point.DocumentID, Text: point.Text}
 		chunks = append(chunks, cc)
 	}
 
@@ -1155,6 +1592,96 @@ func (h Handler) DeleteContext(res *goyave.Response, req *goyave.Request) {
 	}
 }
 
+//
+// Query
+//
+
+// REST counterpart of the websocket vssText message
+func (h Handler) Search(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	workspaceId := req.Params["workspaceId"]
+	query := req.String("query")
+
+	filter := model.VssFilter{DocumentIDs: optionalStrings(req, "documentIds"), TagIDs: optionalStrings(req, "tagIds")}
+
+	llm, _, err := h.workspaceLLM(workspaceId)
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
+	hits, err := h.retrieve(h.EM, llm, orgId, workspaceId, query, filter)
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
+	res.JSON(http.StatusOK, h.contextHolder(hits, query))
+}
+
+// REST counterpart of the websocket analysis message, streamed as Server-Sent Events
+// named after each envelope's updateType, ending with AIResponse or error
+func (h Handler) Ask(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	workspaceId := req.Params["workspaceId"]
+	conversationId := req.Params["conversationId"]
+	claims := req.Extra["jwt_claims"].(*model.ClerkClaims)
+
+	authorName := claims.Subject
+	if req.Has("authorName") {
+		authorName = req.String("authorName")
+	}
+
+	m := model.WebSocketsMessage{
+		WorkspaceID:    workspaceId,
+		ConversationID: conversationId,
+		TemplateID:     sql.NullString{String: req.String("templateId"), Valid: true},
+		QueryText:      req.String("queryText"),
+		ForceContext:   req.String("forceContext"),
+		ResponseSchema: req.String("responseSchema"),
+		DocumentIDs:    optionalStrings(req, "documentIds"),
+		TagIDs:         optionalStrings(req, "tagIds"),
+		AuthorName:     authorName,
+		UserID:         claims.Subject,
+	}
+	if req.Has("variables") {
+		m.Variables = req.Data["variables"].(map[string]any)
+	}
+
+	f, err := flusher(res)
+	if err != nil {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	emit := func(envelope model.Envelope) {
+		data := envelope.Data
+		if envelope.UpdateType == "AIStreamChunk" { // raw text, may contain newlines
+			data, _ = json.Marshal(string(envelope.Data))
+		}
+		fmt.Fprintf(res, "event: %s\ndata: %s\n\n", envelope.UpdateType, data)
+		f.Flush()
+	}
+
+	message, err := h.analyze(h.EM, orgId, m, emit)
+
+	if err == nil {
+		emit(model.AiResponse(message, workspaceId, conversationId))
+	} else {
+		check(err)
+		data, _ := json.Marshal(model.HTTPResponse{Message: err.Error()})
+		emit(model.Envelope{Data: data, UpdateType: "error", WorkspaceID: workspaceId, ConversationID: conversationId})
+	}
+}
+
 //
 // Templates
 //
@@ -1187,7 +1714,14 @@ func (h Handler) CreateTemplate(res *goyave.Response, req *goyave.Request) {
 	templateText := req.String("text")
 	timestamp := time.Now().Format(time.RFC3339)
 
-	result, err := h.PG.CreateTemplate(orgId, workspaceId, templateName, templateText, userId, authorName, timestamp)
+	variables, err := templateVariables(req)
+	if err != nil {
+		res.Status(http.StatusUnprocessableEntity)
+		res.Error(err)
+		return
+	}
+
+	result, err := h.PG.CreateTemplate(orgId, workspaceId, templateName, templateText, variables, userId, authorName, timestamp)
 
 	if err == nil {
 		res.JSON(http.StatusCreated, result)
@@ -1208,12 +1742,88 @@ func (h Handler) GetTemplate(res *goyave.Response, req *goyave.Request) {
 	}
 }
 
+// saves the template as its next version
 func (h Handler) UpdateTemplate(res *goyave.Response, req *goyave.Request) {
+	claims := req.Extra["jwt_claims"].(*model.ClerkClaims)
+	userId := claims.Subject
+	authorName, err := h.PG.GetUserName(userId)
+	check(err)
+
 	templateId := req.Params["templateId"]
 	templateName := req.String("name")
 	templateText := req.String("text")
+	timestamp := time.Now().Format(time.RFC3339)
+
+	variables, err := templateVariables(req)
+	if err != nil {
+		res.Status(http.StatusUnprocessableEntity)
+		res.Error(err)
+		return
+	}
 
-	result, err := h.PG.UpdateTemplate(templateId, templateName, templateText)
+	result, err := h.PG.UpdateTemplate(templateId, templateName, templateText, variables, userId, authorName, timestamp)
+
+	if err == nil {
+		res.JSON(http.StatusOK, result)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+func (h Handler) ListTemplateVersions(res *goyave.Response, req *goyave.Request) {
+	results, err := h.PG.ListTemplateVersions(req.Params["templateId"])
+
+	if err == nil {
+		res.JSON(http.StatusOK, results)
+	} else {
+		res.Status(http.StatusInternalServerError)
+		res.Error(err)
+	}
+}
+
+func (h Handler) GetTemplateVersion(res *goyave.Response, req *goyave.Request) {
+	version, err := strconv.Atoi(req.Params["version"])
+	if err != nil {
+		res.Status(http.StatusBadRequest)
+		res.Error(err)
+		return
+	}
+
+	result, err := h.PG.GetTemplateVersion(req.Params["templateId"], int32(version))
+
+	if err == nil {
+		res.JSON(http.StatusOK, result)
+	} else {
+		res.Status(http.StatusNotFound)
+		res.Error(err)
+	}
+}
+
+// saves an older version of the template as its next version
+func (h Handler) RestoreTemplateVersion(res *goyave.Response, req *goyave.Request) {
+	claims := req.Extra["jwt_claims"].(*model.ClerkClaims)
+	userId := claims.Subject
+	authorName, err := h.PG.GetUserName(userId)
+	check(err)
+
+	templateId := req.Params["templateId"]
+	version, err := strconv.Atoi(req.Params["version"])
+	if err != nil {
+		res.Status(http.StatusBadRequest)
+		res.Error(err)
+		return
+	}
+
+	restored, err := h.PG.GetTemplateVersion(templateId, int32(version))
+	if err != nil {
+		res.Status(http.StatusNotFound)
+		res.Error(err)
+		return
+	}
+
+	timestamp := time.Now().Format(time.RFC3339)
+	result, err := h.PG.UpdateTemplate(templateId, restored.Name, restored.Text, restored.Variables, userId, authorName, timestamp)
 
 	if err == nil {
 		res.JSON(http.StatusOK, result)
@@ -1439,6 +2049,9 @@ func (h Handler) DeleteDriveFolderSync(res *goyave.Response, req *goyave.Request
 		_, err = h.QD.DeleteVectorsByDocumentId(orgId, workspaceId, dSync.DocumentID)
 		check(err)
 
+		err = h.PG.DeleteChunksByDocumentId(dSync.DocumentID)
+		check(err)
+
 		err = h.PG.DeleteDocument(dSync.DocumentID)
 		check(err)
 
@@ -1548,6 +2161,7 @@ func (h Handler) ListDocumentTagAssociations(res *goyave.Response, req *goyave.R
 }
 
 func (h Handler) TagDocument(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
 	workspaceId := req.Params["workspaceId"]
 	documentId := req.Params["documentId"]
 	tagId := req.Params["tagId"]
@@ -1555,6 +2169,11 @@ func (h Handler) TagDocument(res *goyave.Response, req *goyave.Request) {
 	result, err := h.PG.CreateDocumentTagAssociation(workspaceId, documentId, tagId)
 	check(err)
 
+	// keep point payloads in step with postgres
+	if err == nil {
+		err = h.syncDocumentTags(orgId, workspaceId, documentId)
+	}
+
 	if err == nil {
 		res.JSON(http.StatusCreated, result)
 	} else {
@@ -1604,11 +2223,18 @@ func (h Handler) GetDocumentTagAssociation(res *goyave.Response, req *goyave.Req
 }
 
 func (h Handler) UntagDocument(res *goyave.Response, req *goyave.Request) {
+	orgId := req.Params["orgId"]
+	workspaceId := req.Params["workspaceId"]
 	documentId := req.Params["documentId"]
 	tagId := req.Params["tagId"]
 
 	err := h.PG.DeleteDocumentTagAssociation(documentId, tagId)
 
+	// keep point payloads in step with postgres
+	if err == nil {
+		err = h.syncDocumentTags(orgId, workspaceId, documentId)
+	}
+
 	if err == nil {
 		res.JSON(http.StatusOK, model.HTTPResponse{Message: fmt.Sprintf("Deleted document-tag association %s %s", documentId, tagId)})
 	} else {
@@ -1617,10 +2243,85 @@ func (h Handler) UntagDocument(res *goyave.Response, req *goyave.Request) {
 	}
 }
 
+// writes the document's current tag ids into its qdrant payloads
+func (h Handler) syncDocumentTags(orgId string, workspaceId string, documentId string) error {
+	tags, err := h.PG.ListTagsByDocumentId(documentId)
+	if err != nil {
+		return err
+	}
+
+	tagIds := []string{}
+	for _, tag := range tags {
+		tagIds = append(tagIds, tag.ID)
+	}
+
+	return h.QD.SetDocumentTags(orgId, workspaceId, documentId, tagIds)
+}
+
+// autoSnapshot snapshots the org collection before destructive operations when
+// QDRANT_AUTO_SNAPSHOT is set, and records it in auto_snapshots. It returns the
+// snapshot name, empty when orgs without a collection or stores without snapshots
+// are skipped.
+func (h Handler) autoSnapshot(orgId string, operation string) (string, error) {
+	if os.Getenv("QDRANT_AUTO_SNAPSHOT") != "true" {
+		return "", nil
+	}
+
+	_, err := h.QD.GetCollection(orgId)
+	if err != nil {
+		return "", nil
+	}
+
+	snapshot, err := h.QD.CreateSnapshot(orgId)
+	if errors.Is(err, qdrant.ErrSnapshotsUnsupported) {
+		fmt.Println("Skipped snapshot of", orgId+":", err)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println("Created snapshot", snapshot.Name, "of", orgId)
+	return snapshot.Name, h.PG.CreateAutoSnapshot(orgId, snapshot.Name, operation, time.Now().Format(time.RFC3339))
+}
+
+// templateVariables reads and validates the variables a template declares
+func templateVariables(req *goyave.Request) ([]model.TemplateVariable, error) {
+	variables := []model.TemplateVariable{}
+	if req.Has("variables") {
+		data, err := json.Marshal(req.Data["variables"])
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &variables); err != nil {
+			return nil, err
+		}
+	}
+
+	return variables, util.ValidateTemplate(req.String("text"), variables)
+}
+
+// reads an optional array:string field
+func optionalStrings(req *goyave.Request, key string) []string {
+	if req.Has(key) {
+		return req.Data[key].([]string)
+	}
+	return nil
+}
+
 //
 // ERROR HANDLING
 //
 
+// flusher is the writer under a goyave Response, which doesn't implement http.Flusher
+// itself. Streaming handlers get it before sending their headers.
+func flusher(res *goyave.Response) (http.Flusher, error) {
+	if f, ok := res.GetWriter().(http.Flusher); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("response writer %T does not support streaming", res.GetWriter())
+}
+
 func softCheck(err error) {
 	if err != nil {
 		fmt.Println(err)
@@ -11,7 +11,6 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	ws "github.com/gorilla/websocket"
 	"github.com/tmc/langchaingo/embeddings"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 var (
@@ -42,7 +41,6 @@ type Session struct {
 	conversationId string
 	token          *jwt.Token
 	manifest       map[string]map[string]model.FileRecord
-	chatbot        *openai.LLM
 	embedder       *embeddings.EmbedderImpl
 	readErr        chan error
 	writeErr       chan error
@@ -138,8 +136,10 @@ func (s *Session) readPump() {
 				} else {
 					// Query AI with userMessage and history and broadcast AI response
 					go func(message model.WebSocketsMessage) {
-						aiMessage := s.QueryAnalysis(message)
-						s.tracker.Broadcast(model.AiResponse(aiMessage, s.workspaceId, s.conversationId))
+						aiMessage, err := s.QueryAnalysis(message)
+						if err == nil {
+							s.tracker.Broadcast(model.AiResponse(aiMessage, s.workspaceId, s.conversationId))
+						}
 					}(message)
 				}
 			}
@@ -184,6 +184,15 @@ func (s *Session) readPump() {
 				}(folders)
 			}
 
+			// user is searching across all workspaces of the org
+			if envelope.OrgSearch != nil {
+				var search = *envelope.OrgSearch
+
+				go func(search model.OrgSearch) {
+					s.SearchOrg(search)
+				}(search)
+			}
+
 			if envelope.SyncFolders != nil {
 				var folders = *envelope.SyncFolders
 
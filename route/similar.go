@@ -0,0 +1,129 @@
+package route
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"vector-ai/constants"
+	"vector-ai/model"
+	"vector-ai/util"
+)
+
+// errDocumentNotFound is returned for documents outside the workspace or without points
+var errDocumentNotFound = errors.New("document not found")
+
+// similarDocuments searches the workspace with the centroid of a document's chunk
+// vectors and returns the closest other documents, each with the chunk pairs that
+// match best between the two
+func (h Handler) similarDocuments(orgId string, workspaceId string, documentId string) ([]model.SimilarDocument, error) {
+	similar := []model.SimilarDocument{}
+
+	document, err := h.PG.GetDocument(documentId)
+	if err != nil || document.WorkspaceID != workspaceId {
+		return similar, fmt.Errorf("%w: %s in workspace %s", errDocumentNotFound, documentId, workspaceId)
+	}
+
+	source, err := h.QD.GetPointsByDocuments(orgId, workspaceId, []string{documentId})
+	if err != nil {
+		return similar, err
+	}
+	if len(source) == 0 {
+		return similar, fmt.Errorf("%w: %s has no points", errDocumentNotFound, documentId)
+	}
+
+	vectors := [][]float32{}
+	for _, chunk := range source {
+		vectors = append(vectors, chunk.Vector)
+	}
+
+	// one extra document as the source itself is usually the best match
+	options := model.VssOptions{VssDocumentLimit: constants.SimilarDocumentLimit + 1, VssChunkLimit: 1}
+	hits, err := h.QD.Vss(util.Centroid(vectors), orgId, workspaceId, options, model.VssFilter{})
+	if err != nil {
+		return similar, err
+	}
+
+	documentIds := []string{}
+	for _, hit := range hits {
+		if hit.DocumentID == documentId || len(documentIds) == constants.SimilarDocumentLimit {
+			continue
+		}
+		documentIds = append(documentIds, hit.DocumentID)
+		similar = append(similar, model.SimilarDocument{DocumentID: hit.DocumentID, Score: hit.Score})
+	}
+	if len(documentIds) == 0 {
+		return similar, nil
+	}
+
+	candidates, err := h.QD.GetPointsByDocuments(orgId, workspaceId, documentIds)
+	if err != nil {
+		return similar, err
+	}
+
+	chunksByDocument := map[string][]model.Chunk{}
+	for _, chunk := range candidates {
+		chunksByDocument[chunk.DocumentID] = append(chunksByDocument[chunk.DocumentID], chunk)
+	}
+
+	documents, err := h.PG.ListDocuments(workspaceId)
+	if err != nil {
+		return similar, err
+	}
+	names := map[string]string{}
+	for _, document := range documents {
+		names[document.ID] = document.Name
+	}
+
+	for i := range similar {
+		similar[i].Name = names[similar[i].DocumentID]
+		similar[i].Pairs = bestPairs(source, chunksByDocument[similar[i].DocumentID], constants.SimilarChunkPairs)
+	}
+
+	return similar, nil
+}
+
+// bestPairs compares every chunk of both documents and keeps the closest pairs,
+// using each chunk at most once
+func bestPairs(source []model.Chunk, match []model.Chunk, limit int) []model.ChunkPair {
+	type candidate struct {
+		source int
+		match  int
+		score  float32
+	}
+
+	candidates := []candidate{}
+	for i, a := range source {
+		for j, b := range match {
+			candidates = append(candidates, candidate{i, j, util.CosineSimilarity(a.Vector, b.Vector)})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a candidate, b candidate) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	pairs := []model.ChunkPair{}
+	usedSource, usedMatch := map[int]bool{}, map[int]bool{}
+	for _, c := range candidates {
+		if len(pairs) == limit {
+			break
+		}
+		if usedSource[c.source] || usedMatch[c.match] {
+			continue
+		}
+		usedSource[c.source], usedMatch[c.match] = true, true
+
+		pairs = append(pairs, model.ChunkPair{
+			Source: consumable(source[c.source]),
+			Match:  consumable(match[c.match]),
+			Score:  c.score,
+		})
+	}
+
+	return pairs
+}
+
+func consumable(chunk model.Chunk) model.ConsumableContext {
+	return model.ConsumableContext{ID: chunk.ID, WorkspaceID: chunk.WorkspaceID, DocumentID: chunk.DocumentID, Text: chunk.Text}
+}
@@ -0,0 +1,134 @@
+package route
+
+import (
+	"cmp"
+	bg "context"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"vector-ai/constants"
+	"vector-ai/model"
+	"vector-ai/util"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+const TopicTitlePromptTemplate = `
+The following passages were grouped together from a collection of documents:
+
+{{.chunks}}
+
+Write a short title, at most six words, for the topic these passages have in common.
+
+For your output, only provide the title, with no quotes and no other conversational verbiage of any kind.
+`
+
+// k-means iterations per clustering run
+const topicIterations = 25
+
+// ClusterTopics clusters every chunk of a workspace with k-means, titles each
+// cluster from the chunks closest to its centroid and replaces the workspace's
+// stored topics. The number of topics grows with the square root of the chunk count.
+func (h Handler) ClusterTopics(orgId string, workspaceId string) ([]model.Topic, error) {
+	chunks := []model.Chunk{}
+	it := h.QD.Scroll(orgId, workspaceId, true)
+	for it.Next() {
+		chunks = append(chunks, it.Page()...)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	documents, err := h.PG.ListDocuments(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, document := range documents {
+		names[document.ID] = document.Name
+	}
+
+	// orphan points have no document to browse to
+	chunks = slices.DeleteFunc(chunks, func(chunk model.Chunk) bool {
+		_, ok := names[chunk.DocumentID]
+		return !ok
+	})
+
+	vectors := [][]float32{}
+	for _, chunk := range chunks {
+		vectors = append(vectors, chunk.Vector)
+	}
+
+	k := min(constants.TopicLimit, max(2, int(math.Sqrt(float64(len(chunks))/10))))
+	assignments, centroids := util.KMeans(vectors, k, topicIterations)
+
+	members := make([][]model.Chunk, len(centroids))
+	for i, chunk := range chunks {
+		members[assignments[i]] = append(members[assignments[i]], chunk)
+	}
+
+	llm, _, err := h.workspaceLLM(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := []model.Topic{}
+	for c, cluster := range members {
+		if len(cluster) == 0 {
+			continue
+		}
+
+		title, err := topicTitle(llm, cluster, centroids[c])
+		if err != nil {
+			return nil, err
+		}
+
+		counts := map[string]int64{}
+		for _, chunk := range cluster {
+			counts[chunk.DocumentID]++
+		}
+
+		topic := model.Topic{WorkspaceID: workspaceId, Title: title, Chunks: int64(len(cluster)), Documents: []model.TopicDocument{}}
+		for documentId, count := range counts {
+			topic.Documents = append(topic.Documents, model.TopicDocument{DocumentID: documentId, Name: names[documentId], Chunks: count})
+		}
+		topics = append(topics, topic)
+	}
+
+	err = h.PG.ReplaceTopics(workspaceId, topics, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	return h.PG.ListTopics(workspaceId)
+}
+
+// topicTitle asks the LLM to name a cluster from its most central chunks
+func topicTitle(llm llms.Model, cluster []model.Chunk, centroid []float32) (string, error) {
+	central := slices.Clone(cluster)
+	slices.SortFunc(central, func(a model.Chunk, b model.Chunk) int {
+		return cmp.Compare(util.CosineSimilarity(b.Vector, centroid), util.CosineSimilarity(a.Vector, centroid))
+	})
+
+	samples := []string{}
+	for _, chunk := range central[:min(len(central), constants.TopicSampleChunks)] {
+		samples = append(samples, chunk.Text)
+	}
+
+	prompt := prompts.NewPromptTemplate(TopicTitlePromptTemplate, []string{"chunks"})
+	constructedPrompt, err := prompt.Format(map[string]any{
+		"chunks": strings.Join(samples, "\n\n---\n\n"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	completion, err := llms.GenerateFromSinglePrompt(bg.Background(), llm, constructedPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(strings.TrimSpace(completion), `"'`), nil
+}
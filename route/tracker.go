@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
-	"vector-ai/constants"
+	"time"
 	"vector-ai/model"
+	"vector-ai/provider"
 
 	"github.com/gorilla/websocket"
-	"github.com/tmc/langchaingo/embeddings"
-	"github.com/tmc/langchaingo/llms/openai"
 	"goyave.dev/goyave/v4"
 )
 
@@ -23,6 +22,13 @@ type Tracker struct {
 	ctx     context.Context
 	cancel  context.CancelFunc
 	handler Handler
+
+	// documents whose points are written before their row is
+	uploads sync.Map
+
+	// latest topic clustering run by workspaceId
+	topicJobs   map[string]model.TopicJob
+	topicJobsMu sync.Mutex
 }
 
 // create a new tracker //pgx *pgxpool.Pool, qdr *qdrantgo.Client
@@ -33,6 +39,7 @@ func NewTracker() *Tracker {
 		register:   make(chan *Session),
 		unregister: make(chan *Session),
 		sessions:   make(map[string]map[string]map[*connection]bool),
+		topicJobs:  make(map[string]model.TopicJob),
 		ctx:        ctx,
 		cancel:     cancel,
 		// handler:    h,
@@ -150,16 +157,7 @@ func (t *Tracker) Serve(res *goyave.Response, req *goyave.Request) {
 	workspaceId := req.Params["workspaceId"]
 	conversationId := req.Params["conversationId"]
 
-	opts := []openai.Option{
-		openai.WithModel(constants.LLM),
-		openai.WithEmbeddingModel(constants.Embedder),
-	}
-
-	llm, err := openai.New(opts...)
-	check(err)
-
-	// embeddingOptions := []embeddings.Option{}
-	embedder, err := embeddings.NewEmbedder(llm)
+	embedder, err := provider.NewEmbedder()
 	check(err)
 
 	session := &Session{
@@ -170,7 +168,6 @@ func (t *Tracker) Serve(res *goyave.Response, req *goyave.Request) {
 		workspaceId:    workspaceId,
 		conversationId: conversationId,
 		manifest:       make(map[string]map[string]model.FileRecord),
-		chatbot:        llm,
 		embedder:       embedder,
 		readErr:        make(chan error, 1),
 		writeErr:       make(chan error, 1),
@@ -195,3 +192,59 @@ func (t *Tracker) Broadcast(res model.Envelope) {
 	case t.broadcast <- res: // move to session
 	}
 }
+
+// StartUpload marks a new document as uploading until FinishUpload
+func (t *Tracker) StartUpload(documentId string) {
+	t.uploads.Store(documentId, true)
+}
+
+func (t *Tracker) FinishUpload(documentId string) {
+	t.uploads.Delete(documentId)
+}
+
+// Uploading reports whether a document's points may not have their row yet
+func (t *Tracker) Uploading(documentId string) bool {
+	_, ok := t.uploads.Load(documentId)
+	return ok
+}
+
+// StartTopicJob records a clustering run of the workspace, unless one is running
+func (t *Tracker) StartTopicJob(workspaceId string) bool {
+	t.topicJobsMu.Lock()
+	defer t.topicJobsMu.Unlock()
+
+	if t.topicJobs[workspaceId].Status == model.TopicJobRunning {
+		return false
+	}
+	t.topicJobs[workspaceId] = model.TopicJob{Status: model.TopicJobRunning, Started: time.Now()}
+	return true
+}
+
+// FinishTopicJob records the outcome of the workspace's running clustering run
+func (t *Tracker) FinishTopicJob(workspaceId string, topics int, err error) {
+	t.topicJobsMu.Lock()
+	defer t.topicJobsMu.Unlock()
+
+	job := t.topicJobs[workspaceId]
+	finished := time.Now()
+	job.Finished = &finished
+	job.Status = model.TopicJobDone
+	job.Topics = topics
+	if err != nil {
+		job.Status = model.TopicJobFailed
+		job.Error = err.Error()
+	}
+	t.topicJobs[workspaceId] = job
+}
+
+// TopicJob returns the workspace's latest clustering run, nil if there was none
+func (t *Tracker) TopicJob(workspaceId string) *model.TopicJob {
+	t.topicJobsMu.Lock()
+	defer t.topicJobsMu.Unlock()
+
+	job, ok := t.topicJobs[workspaceId]
+	if !ok {
+		return nil
+	}
+	return &job
+}
@@ -56,6 +56,7 @@ func (s Session) UploadFile(header model.CoreDocumentProps, data []byte) {
 		OrgID:       orgId,
 		DocumentID:  documentId,
 		WorkspaceID: workspaceId,
+		UserID:      userId,
 	}
 
 	_, ok := s.manifest[folderId]
@@ -100,10 +101,18 @@ func (s Session) UploadFile(header model.CoreDocumentProps, data []byte) {
 
 	go func(profile model.NewLocalProfile, vsp model.VectorStorageProfile) {
 		// defer wg.Done()
+		s.handler.TR.StartUpload(documentId)
+		defer s.handler.TR.FinishUpload(documentId)
+
 		var evs model.EventStream
 		evs, parsedDoc := s.handler.parseLocalUpload(evs, profile)
-		evs, chunks := s.handler.splitEmbedUpload(evs, vsp, parsedDoc, s.embedder, options)
-		evs = s.handler.saveLocalDocument(evs, profile, chunks, options)
+		evs, skip := s.handler.checkDuplicate(evs, profile.ManifestData, parsedDoc, true)
+		if !skip {
+			var chunks int64
+			evs, chunks = s.handler.splitEmbedUpload(evs, vsp, parsedDoc, s.embedder, options)
+			evs = s.handler.saveLocalDocument(evs, profile, chunks, options)
+			s.handler.setContentHash(evs, documentId, parsedDoc)
+		}
 
 		record.EventStream = evs
 		record.OperationSuccessful = true
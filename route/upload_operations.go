@@ -10,6 +10,8 @@ import (
 	"vector-ai/drive"
 	"vector-ai/model"
 	"vector-ai/parse"
+	"vector-ai/provider"
+	"vector-ai/qdrant"
 	"vector-ai/util"
 
 	stripe "github.com/stripe/stripe-go/v76"
@@ -74,6 +76,16 @@ func (h Handler) splitEmbedUpload(evs model.EventStream, vsp model.VectorStorage
 	floats, err := embedder.EmbedDocuments(ctx, chunks)
 	ctx.Done()
 
+	if err == nil {
+		spent := provider.EmbeddingUsage(chunks)
+		spent.Operation = model.UsageEmbedding
+		spent.OrgID = orgId
+		spent.WorkspaceID = workspaceId
+		spent.DocumentID = documentId
+		spent.UserID = vsp.UserID
+		h.recordUsage(spent)
+	}
+
 	event = h.broadcast("Embedding", "Completed", workspaceId, documentId, err)
 	evs.Events = append(evs.Events, event)
 	event = h.broadcast("Uploading", "Started", workspaceId, documentId, nil)
@@ -82,6 +94,24 @@ func (h Handler) splitEmbedUpload(evs model.EventStream, vsp model.VectorStorage
 	result, err := h.QD.Upload(orgId, workspaceId, documentId, floats, chunks)
 	fmt.Println(result)
 
+	// mirror chunk text for keyword search
+	if err == nil {
+		pointIds := []string{}
+		for i, chunk := range chunks {
+			pointIds = append(pointIds, qdrant.PointId(documentId, i, chunk))
+		}
+		_, err = h.PG.CreateChunks(workspaceId, documentId, pointIds, chunks)
+	}
+
+	// re-uploaded documents keep their tags
+	if err == nil {
+		var tags []model.Tag
+		tags, err = h.PG.ListTagsByDocumentId(documentId)
+		if err == nil && len(tags) > 0 {
+			err = h.syncDocumentTags(orgId, workspaceId, documentId)
+		}
+	}
+
 	event = h.broadcast("Uploading", "Completed", workspaceId, documentId, err)
 	evs.Events = append(evs.Events, event)
 
@@ -279,6 +309,10 @@ func (h Handler) DeleteVectors(evs model.EventStream, vsp model.VectorStoragePro
 	detail := fmt.Sprintf("Deleted %d points", pointsDeleted)
 	fmt.Println(detail)
 
+	if err == nil {
+		err = h.PG.DeleteChunksByDocumentId(documentId)
+	}
+
 	event = h.broadcast("Deleting", "Completed", workspaceId, documentId, err)
 	evs.Events = append(evs.Events, event)
 
@@ -301,12 +335,12 @@ func (h Handler) broadcast(op string, action string, workspaceId string, documen
 func progress(status string) int {
 
 	// Manual:
-	// Opening - Parsing
+	// Opening - Parsing - Deduplicating
 	// Splitting - Embedding - Uploading
 	// Updating
 
 	// Drive Sync:
-	// Downloading/Exporting - Parsing
+	// Downloading/Exporting - Parsing - Deduplicating
 	// Splitting - Embedding - Uploading
 	// Updating - Synchronizing
 
@@ -316,6 +350,8 @@ func progress(status string) int {
 		return 10
 	} else if status == ("Parsing") {
 		return 15
+	} else if status == ("Deduplicating") {
+		return 20
 	} else if status == ("Splitting") {
 		return 30
 	} else if status == ("Embedding") {
@@ -339,8 +375,26 @@ func (h Handler) createUsageEvent(orgId string) (*stripe.UsageRecord, error) {
 
 	totalFileSizeAmount, err := h.PG.GetTotalFileSizeAmount(orgId)
 	check(err)
-	_, err = h.PG.CreateUsageEvent(orgId, totalFileSizeAmount, time.Now().Format(time.RFC3339))
-	check(err) // broadcast
+
+	// tokens spent since the previous usage event. Seconds only, as the event stores
+	// them, so the next window starts where this one ends.
+	now := time.Now().UTC().Truncate(time.Second)
+	since, err := h.PG.GetLatestUsageEventTimestamp(orgId)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := h.PG.SumTokenUsage(model.UsageFilter{OrgID: orgId, From: since, To: now})
+	if err != nil {
+		return nil, err
+	}
+
+	// the event closes the window before Stripe is told, so a failed write can't
+	// bill tokens twice. Its tokens stay unreported until Stripe has them.
+	priceId := os.Getenv("STRIPE_TOKEN_PRICE_ID")
+	_, err = h.PG.CreateUsageEvent(orgId, totalFileSizeAmount, tokens, priceId == "" || tokens.Calls == 0, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
 
 	fmt.Println("tfsa", totalFileSizeAmount)
 
@@ -369,5 +423,67 @@ func (h Handler) createUsageEvent(orgId string) (*stripe.UsageRecord, error) {
 		SubscriptionItem: stripe.String(si.ID),
 	}
 
+	// report tokens on the subscription's token price, if it has one, separately from
+	// storage. Events a previous report failed on are retried under the same key.
+	if priceId != "" {
+		h.reportTokenUsage(orgId, stripeId, priceId)
+	}
+
 	return usagerecord.New(urParams)
 }
+
+// reportTokenUsage adds the tokens of each unreported usage event, in thousands, to
+// the subscription item billed at priceId. The event id is the idempotency key, so
+// Stripe counts an event once however often it is retried.
+func (h Handler) reportTokenUsage(orgId string, stripeId string, priceId string) {
+	events, err := h.PG.ListUnreportedUsageEvents(orgId)
+	if err != nil {
+		check(err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	itemId, err := subscriptionItemId(stripeId, priceId)
+	if err != nil {
+		check(err)
+		return
+	}
+
+	for _, event := range events {
+		total := event.PromptTokens + event.CompletionTokens + event.EmbeddingTokens
+		urParams := &stripe.UsageRecordParams{
+			Quantity:         stripe.Int64((total + 999) / 1000),
+			SubscriptionItem: stripe.String(itemId),
+			Action:           stripe.String(stripe.UsageRecordActionIncrement),
+		}
+		urParams.SetIdempotencyKey("usage-event-tokens-" + event.ID)
+
+		if _, err := usagerecord.New(urParams); err != nil {
+			check(err)
+			return
+		}
+		check(h.PG.SetUsageEventTokensReported(event.ID))
+	}
+}
+
+// subscriptionItemId finds the item of the subscription billed at priceId
+func subscriptionItemId(stripeId string, priceId string) (string, error) {
+	params := &stripe.SubscriptionItemListParams{
+		Subscription: stripe.String(stripeId),
+	}
+	result := subscriptionitem.List(params)
+
+	for result.Next() {
+		si := result.SubscriptionItem()
+		if si.Price != nil && si.Price.ID == priceId {
+			return si.ID, nil
+		}
+	}
+
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("subscription %s has no item with price %s", stripeId, priceId)
+}
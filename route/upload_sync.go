@@ -58,6 +58,9 @@ func (s Session) SyncDrive(folderIds []string) {
 	documentSyncs, err := s.handler.PG.ListDriveDocumentSync(workspaceId)
 	check(err)
 
+	skipped, err := s.handler.PG.ListDriveSkippedDocuments(workspaceId)
+	check(err)
+
 	syncReport, err := s.handler.createSyncReport(folderSyncs, documentSyncs)
 	check(err)
 
@@ -70,6 +73,7 @@ func (s Session) SyncDrive(folderIds []string) {
 		folderId := folderReport.DriveID
 		// folderName := folderReport.Name
 		syncReport := folderReport.SyncReport
+		syncReport.New = withoutSkipped(syncReport.New, skipped)
 
 		// check for folderId in manifest
 		_, ok := s.manifest[folderId]
@@ -148,6 +152,7 @@ func (s Session) SyncDrive(folderIds []string) {
 					OrgID:       orgId,
 					WorkspaceID: profile.WorkspaceID,
 					DocumentID:  profile.DocumentID,
+					UserID:      userId,
 				}
 
 				guard <- struct{}{}
@@ -155,11 +160,22 @@ func (s Session) SyncDrive(folderIds []string) {
 
 				go func(profile model.NewDriveProfile, dlp model.DownloadProfile, vsp model.VectorStorageProfile) {
 					defer wg.Done()
+					s.handler.TR.StartUpload(profile.DocumentID)
+					defer s.handler.TR.FinishUpload(profile.DocumentID)
+
 					var evs model.EventStream
 					evs, body, exportType := s.handler.downloadDriveFile(evs, dlp)
 					evs, parsedDoc := s.handler.parseBody(evs, profile.ManifestData, body, exportType)
-					evs, chunks := s.handler.splitEmbedUpload(evs, vsp, parsedDoc, s.embedder, options)
-					evs = s.handler.syncNew(evs, profile, chunks, options)
+					// skipped duplicates get no sync entry, they are left out of syncs until they change
+					evs, skip := s.handler.checkDuplicate(evs, profile.ManifestData, parsedDoc, true)
+					if skip {
+						check(s.handler.PG.CreateDriveSkippedDocument(workspaceId, profile.DriveID, profile.LastModified.Format(time.RFC3339)))
+					} else {
+						var chunks int64
+						evs, chunks = s.handler.splitEmbedUpload(evs, vsp, parsedDoc, s.embedder, options)
+						evs = s.handler.syncNew(evs, profile, chunks, options)
+						s.handler.setContentHash(evs, profile.DocumentID, parsedDoc)
+					}
 
 					documentId := profile.DocumentID
 					record := s.manifest[folderId][documentId]
@@ -190,6 +206,7 @@ func (s Session) SyncDrive(folderIds []string) {
 					OrgID:       orgId,
 					WorkspaceID: profile.WorkspaceID,
 					DocumentID:  profile.DocumentID,
+					UserID:      userId,
 				}
 
 				guard <- struct{}{}
@@ -198,11 +215,14 @@ func (s Session) SyncDrive(folderIds []string) {
 				go func(profile model.UpdatedDriveProfile, dlp model.DownloadProfile, vsp model.VectorStorageProfile) {
 					defer wg.Done()
 					var evs model.EventStream
-					evs = s.handler.DeleteVectors(evs, vsp)
+					// point ids are deterministic, Upload overwrites in place and prunes stale chunks
 					evs, body, exportType := s.handler.downloadDriveFile(evs, dlp)
 					evs, parsedDoc := s.handler.parseBody(evs, profile.ManifestData, body, exportType)
+					// an updated document is never skipped, that would leave its old version indexed
+					evs, _ = s.handler.checkDuplicate(evs, profile.ManifestData, parsedDoc, false)
 					evs, chunks := s.handler.splitEmbedUpload(evs, vsp, parsedDoc, s.embedder, options)
 					evs = s.handler.syncUpdated(evs, profile, chunks, options)
+					s.handler.setContentHash(evs, profile.DocumentID, parsedDoc)
 
 					documentId := profile.DocumentID
 					record := s.manifest[folderId][documentId]
@@ -228,6 +248,7 @@ func (s Session) SyncDrive(folderIds []string) {
 					OrgID:       orgId,
 					WorkspaceID: profile.WorkspaceID,
 					DocumentID:  profile.DocumentID,
+					UserID:      userId,
 				}
 
 				guard <- struct{}{}
@@ -0,0 +1,52 @@
+package route
+
+import (
+	"fmt"
+	"time"
+	"vector-ai/model"
+	"vector-ai/provider"
+)
+
+// recordUsage prices and saves the tokens of a call. Accounting never fails the call
+// itself, errors are only logged.
+func (h Handler) recordUsage(usage model.TokenUsage) {
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 && usage.EmbeddingTokens == 0 {
+		return
+	}
+
+	usage.CostMicros = provider.Cost(usage)
+	_, err := h.PG.CreateTokenUsage(usage, time.Now().UTC().Format(time.RFC3339))
+	softCheck(err)
+}
+
+// usageReport totals the usage matching filter and breaks it down per interval,
+// model and operation
+func (h Handler) usageReport(filter model.UsageFilter, interval string) (model.UsageReport, error) {
+	report := model.UsageReport{From: filter.From, To: filter.To, Interval: interval}
+
+	totals, err := h.PG.SumTokenUsage(filter)
+	if err != nil {
+		return report, err
+	}
+	report.Totals = totals
+
+	report.Buckets, err = h.PG.ListTokenUsageBuckets(filter, interval)
+	return report, err
+}
+
+// usageFilter reads the from and to dates of a usage request, both inclusive. The
+// last 30 days are reported by default.
+func usageFilter(orgId string, from time.Time, to time.Time) (model.UsageFilter, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if to.IsZero() {
+		to = today
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -29)
+	}
+	if from.After(to) {
+		return model.UsageFilter{}, fmt.Errorf("from %s is after to %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+
+	return model.UsageFilter{OrgID: orgId, From: from.UTC(), To: to.UTC().AddDate(0, 0, 1)}, nil
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+)
+
+// KMeans clusters vectors by cosine similarity (spherical k-means) and returns
+// the cluster of each vector and the unit length centroids. Seeding uses
+// k-means++ with a fixed seed so reruns on the same data give the same topics.
+func KMeans(vectors [][]float32, k int, iterations int) ([]int, [][]float32) {
+	assignments := make([]int, len(vectors))
+	if len(vectors) == 0 || k <= 0 {
+		return assignments, nil
+	}
+	k = min(k, len(vectors))
+
+	rng := rand.New(rand.NewSource(1))
+
+	// k-means++: each next centroid is picked with probability proportional to its distance
+	centroids := [][]float32{vectors[rng.Intn(len(vectors))]}
+	distances := make([]float64, len(vectors))
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
+	for len(centroids) < k {
+		var total float64
+		for i, vector := range vectors {
+			distances[i] = min(distances[i], 1-float64(CosineSimilarity(vector, centroids[len(centroids)-1])))
+			total += distances[i]
+		}
+		if total == 0 {
+			break // fewer distinct vectors than clusters
+		}
+
+		target := rng.Float64() * total
+		next := len(vectors) - 1
+		for i, d := range distances {
+			target -= d
+			if target <= 0 {
+				next = i
+				break
+			}
+		}
+		centroids = append(centroids, vectors[next])
+	}
+
+	for range iterations {
+		changed := false
+		for i, vector := range vectors {
+			best, bestScore := 0, float32(-2)
+			for c, centroid := range centroids {
+				if score := CosineSimilarity(vector, centroid); score > bestScore {
+					best, bestScore = c, score
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		members := make([][][]float32, len(centroids))
+		for i, vector := range vectors {
+			members[assignments[i]] = append(members[assignments[i]], vector)
+		}
+		for c := range centroids {
+			if len(members[c]) > 0 {
+				centroids[c] = Centroid(members[c])
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	return assignments, centroids
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKMeans(t *testing.T) {
+	tests := []struct {
+		name    string
+		vectors [][]float32
+		k       int
+		groups  [][]int // vectors expected in the same cluster
+	}{
+		{
+			name:    "no vectors",
+			vectors: [][]float32{},
+			k:       3,
+			groups:  [][]int{},
+		},
+		{
+			name: "two directions",
+			vectors: [][]float32{
+				{1, 0.1}, {0.9, 0}, {1, -0.1},
+				{0, 1}, {0.1, 0.9}, {-0.1, 1},
+			},
+			k:      2,
+			groups: [][]int{{0, 1, 2}, {3, 4, 5}},
+		},
+		{
+			name:    "more clusters than vectors",
+			vectors: [][]float32{{1, 0}, {0, 1}},
+			k:       5,
+			groups:  [][]int{{0}, {1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments, centroids := KMeans(tt.vectors, tt.k, 25)
+
+			if len(assignments) != len(tt.vectors) {
+				t.Fatalf("got %d assignments for %d vectors", len(assignments), len(tt.vectors))
+			}
+			if len(centroids) > tt.k {
+				t.Errorf("got %d centroids, asked for %d", len(centroids), tt.k)
+			}
+
+			clusters := map[int]bool{}
+			for _, group := range tt.groups {
+				cluster := assignments[group[0]]
+				if clusters[cluster] {
+					t.Errorf("groups share cluster %d: %v", cluster, assignments)
+				}
+				clusters[cluster] = true
+
+				for _, i := range group {
+					if assignments[i] != cluster {
+						t.Errorf("vector %d in cluster %d, want %d", i, assignments[i], cluster)
+					}
+				}
+			}
+
+			for c, centroid := range centroids {
+				var norm float64
+				for _, v := range centroid {
+					norm += float64(v) * float64(v)
+				}
+				if math.Abs(norm-1) > 1e-4 {
+					t.Errorf("centroid %d has length %v, want 1", c, math.Sqrt(norm))
+				}
+			}
+		})
+	}
+}
+
+func TestKMeansIsDeterministic(t *testing.T) {
+	vectors := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 1, 0}, {0, 1, 1}, {1, 0, 1}}
+
+	first, _ := KMeans(vectors, 3, 25)
+	second, _ := KMeans(vectors, 3, 25)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("reruns differ: %v and %v", first, second)
+		}
+	}
+}
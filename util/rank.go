@@ -0,0 +1,177 @@
+package util
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"vector-ai/model"
+)
+
+// smoothing constant from the original RRF paper
+const RrfK = 60
+
+// how many more chunks per document are fetched as MMR candidates
+const MmrFetchFactor = 4
+
+// ReciprocalRankFusion merges ranked lists by summing 1/(k+rank) per point id.
+// The fused score replaces each hit's original score.
+func ReciprocalRankFusion(lists [][]model.Hit, k int) []model.Hit {
+	scores := map[string]float32{}
+	hits := map[string]model.Hit{}
+	order := []string{}
+
+	for _, list := range lists {
+		for rank, hit := range list {
+			if _, ok := hits[hit.ID]; !ok {
+				hits[hit.ID] = hit
+				order = append(order, hit.ID)
+			}
+			scores[hit.ID] += 1 / float32(k+rank+1)
+		}
+	}
+
+	fused := []model.Hit{}
+	for _, id := range order {
+		hit := hits[id]
+		hit.Score = scores[id]
+		fused = append(fused, hit)
+	}
+
+	slices.SortStableFunc(fused, func(a model.Hit, b model.Hit) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return fused
+}
+
+// LimitPerDocument keeps at most chunkLimit hits from each of the first documentLimit documents,
+// preserving rank order
+func LimitPerDocument(hits []model.Hit, documentLimit uint32, chunkLimit uint32) []model.Hit {
+	perDocument := map[string]uint32{}
+	limited := []model.Hit{}
+
+	for _, hit := range hits {
+		count, seen := perDocument[hit.DocumentID]
+		if !seen && uint32(len(perDocument)) >= documentLimit {
+			continue
+		}
+		if count >= chunkLimit {
+			continue
+		}
+		perDocument[hit.DocumentID] = count + 1
+		limited = append(limited, hit)
+	}
+
+	return limited
+}
+
+// MaximalMarginalRelevance greedily picks hits that are relevant to the query but
+// dissimilar to those already picked, honouring the same per-document limits as
+// LimitPerDocument. lambda 1 is pure relevance, 0 pure diversity. Hits without a
+// vector are treated as dissimilar to everything.
+func MaximalMarginalRelevance(hits []model.Hit, lambda float32, documentLimit uint32, chunkLimit uint32) []model.Hit {
+	if len(hits) == 0 {
+		return hits
+	}
+
+	// normalise relevance so fused and raw scores weigh the same against similarity
+	low, high := hits[0].Score, hits[0].Score
+	for _, hit := range hits {
+		low = min(low, hit.Score)
+		high = max(high, hit.Score)
+	}
+	relevance := func(hit model.Hit) float32 {
+		if high == low {
+			return 1
+		}
+		return (hit.Score - low) / (high - low)
+	}
+
+	k := int(documentLimit * chunkLimit)
+	perDocument := map[string]uint32{}
+	picked := make([]bool, len(hits))
+	selected := []model.Hit{}
+
+	for len(selected) < k {
+		best := -1
+		var bestScore float32
+
+		for i, hit := range hits {
+			if picked[i] {
+				continue
+			}
+			count, seen := perDocument[hit.DocumentID]
+			if (!seen && uint32(len(perDocument)) >= documentLimit) || count >= chunkLimit {
+				continue
+			}
+
+			var redundancy float32
+			for _, s := range selected {
+				redundancy = max(redundancy, CosineSimilarity(hit.Vector, s.Vector))
+			}
+
+			score := lambda*relevance(hit) - (1-lambda)*redundancy
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		picked[best] = true
+		perDocument[hits[best].DocumentID]++
+		selected = append(selected, hits[best])
+	}
+
+	return selected
+}
+
+// Centroid is the mean of the given vectors, normalized to unit length so it
+// scores like a chunk embedding on dot product collections
+func Centroid(vectors [][]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	sum := make([]float64, len(vectors[0]))
+	for _, vector := range vectors {
+		for i := range min(len(sum), len(vector)) {
+			sum[i] += float64(vector[i])
+		}
+	}
+
+	var norm float64
+	for _, v := range sum {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	centroid := make([]float32, len(sum))
+	for i, v := range sum {
+		if norm > 0 {
+			centroid[i] = float32(v / norm)
+		}
+	}
+
+	return centroid
+}
+
+func CosineSimilarity(a []float32, b []float32) float32 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"slices"
+	"testing"
+	"vector-ai/model"
+)
+
+func hitIds(hits []model.Hit) []string {
+	ids := []string{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	tests := []struct {
+		name  string
+		lists [][]model.Hit
+		want  []string
+	}{
+		{
+			name:  "empty",
+			lists: [][]model.Hit{},
+			want:  []string{},
+		},
+		{
+			name:  "single list keeps its order",
+			lists: [][]model.Hit{{{ID: "a"}, {ID: "b"}, {ID: "c"}}},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name: "points in both lists rise",
+			lists: [][]model.Hit{
+				{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+				{{ID: "c"}, {ID: "d"}},
+			},
+			want: []string{"c", "a", "b", "d"},
+		},
+		{
+			name: "ties keep first seen order",
+			lists: [][]model.Hit{
+				{{ID: "a"}},
+				{{ID: "b"}},
+			},
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReciprocalRankFusion(tt.lists, RrfK)
+			if !slices.Equal(hitIds(got), tt.want) {
+				t.Errorf("got %v, want %v", hitIds(got), tt.want)
+			}
+		})
+	}
+
+	fused := ReciprocalRankFusion([][]model.Hit{{{ID: "a", Score: 0.9}}, {{ID: "a", Score: 0.1}}}, RrfK)
+	if want := float32(2) / float32(RrfK+1); fused[0].Score != want {
+		t.Errorf("fused score %v, want %v", fused[0].Score, want)
+	}
+}
+
+func TestMaximalMarginalRelevance(t *testing.T) {
+	x := []float32{1, 0}
+	y := []float32{0, 1}
+	hits := []model.Hit{
+		{ID: "a1", DocumentID: "a", Score: 0.9, Vector: x},
+		{ID: "a2", DocumentID: "a", Score: 0.8, Vector: x},
+		{ID: "b1", DocumentID: "b", Score: 0.7, Vector: y},
+		{ID: "c1", DocumentID: "c", Score: 0.1, Vector: x},
+	}
+
+	tests := []struct {
+		name          string
+		hits          []model.Hit
+		lambda        float32
+		documentLimit uint32
+		chunkLimit    uint32
+		want          []string
+	}{
+		{
+			name:          "no hits",
+			hits:          []model.Hit{},
+			lambda:        0.5,
+			documentLimit: 3,
+			chunkLimit:    2,
+			want:          []string{},
+		},
+		{
+			name:          "pure relevance is rank order",
+			hits:          hits,
+			lambda:        1,
+			documentLimit: 3,
+			chunkLimit:    2,
+			want:          []string{"a1", "a2", "b1", "c1"},
+		},
+		{
+			name:          "diversity skips the repeated vector",
+			hits:          hits,
+			lambda:        0.5,
+			documentLimit: 3,
+			chunkLimit:    2,
+			want:          []string{"a1", "b1", "a2", "c1"},
+		},
+		{
+			name:          "per document limits hold",
+			hits:          hits,
+			lambda:        1,
+			documentLimit: 2,
+			chunkLimit:    1,
+			want:          []string{"a1", "b1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MaximalMarginalRelevance(tt.hits, tt.lambda, tt.documentLimit, tt.chunkLimit)
+			if !slices.Equal(hitIds(got), tt.want) {
+				t.Errorf("got %v, want %v", hitIds(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitPerDocument(t *testing.T) {
+	hits := []model.Hit{
+		{ID: "a1", DocumentID: "a"},
+		{ID: "b1", DocumentID: "b"},
+		{ID: "a2", DocumentID: "a"},
+		{ID: "c1", DocumentID: "c"},
+		{ID: "a3", DocumentID: "a"},
+	}
+
+	got := LimitPerDocument(hits, 2, 2)
+	if want := []string{"a1", "b1", "a2"}; !slices.Equal(hitIds(got), want) {
+		t.Errorf("got %v, want %v", hitIds(got), want)
+	}
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var codeFence = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\\n(.*?)\\n?```\\s*$")
+
+// StripCodeFence removes the markdown fence models wrap around YAML despite being
+// told not to
+func StripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if match := codeFence.FindStringSubmatch(text); match != nil {
+		return match[1]
+	}
+	return text
+}
+
+// ParseStructured parses a YAML response and validates it against a YAML schema,
+// returning the response as JSON. The JSON is returned along with validation
+// errors when the response parses but doesn't match.
+func ParseStructured(response string, schema string) ([]byte, error) {
+	var value any
+	if err := yaml.Unmarshal([]byte(StripCodeFence(response)), &value); err != nil {
+		return nil, fmt.Errorf("response is not valid YAML: %v", err)
+	}
+
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("response can not be converted to JSON: %v", err)
+	}
+
+	var schemaValue any
+	if err := yaml.Unmarshal([]byte(schema), &schemaValue); err != nil || schemaValue == nil {
+		return jsonBytes, nil // free-form schemas are only described to the model
+	}
+
+	return jsonBytes, matchSchema(value, schemaValue, "$")
+}
+
+// matchSchema checks that value has the schema's shape: every schema key present,
+// lists of the schema's first element, and scalars of the named type when the
+// schema gives one (string, number, integer, boolean), any scalar otherwise
+func matchSchema(value any, schema any, path string) error {
+	switch s := schema.(type) {
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s should be a mapping", path)
+		}
+		for key, child := range s {
+			field, ok := v[key]
+			if !ok {
+				return fmt.Errorf("%s.%s is missing", path, key)
+			}
+			if err := matchSchema(field, child, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case []any:
+		v, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s should be a list", path)
+		}
+		if len(s) == 0 {
+			return nil
+		}
+		for i, item := range v {
+			if err := matchSchema(item, s[0], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case string:
+		return matchScalar(value, strings.ToLower(strings.TrimSpace(s)), path)
+	default:
+		if _, ok := value.(map[string]any); ok {
+			return fmt.Errorf("%s should be a single value", path)
+		}
+		if _, ok := value.([]any); ok {
+			return fmt.Errorf("%s should be a single value", path)
+		}
+	}
+
+	return nil
+}
+
+func matchScalar(value any, typeName string, path string) error {
+	var ok bool
+	switch typeName {
+	case "string", "str", "text":
+		_, ok = value.(string)
+	case "number", "float":
+		switch value.(type) {
+		case int, float64:
+			ok = true
+		}
+	case "integer", "int":
+		_, ok = value.(int)
+	case "boolean", "bool":
+		_, ok = value.(bool)
+	default: // an example value rather than a type
+		switch value.(type) {
+		case map[string]any, []any:
+			return fmt.Errorf("%s should be a single value", path)
+		}
+		return nil
+	}
+
+	if !ok {
+		return fmt.Errorf("%s should be a %s", path, typeName)
+	}
+	return nil
+}
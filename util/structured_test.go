@@ -0,0 +1,84 @@
+package util
+
+import "testing"
+
+func TestParseStructured(t *testing.T) {
+	schema := `
+title: string
+score: number
+tags:
+  - string
+`
+
+	tests := []struct {
+		name     string
+		response string
+		schema   string
+		want     string // JSON, empty when nothing parses
+		wantErr  bool
+	}{
+		{
+			name:     "matches",
+			response: "title: Report\nscore: 4.5\ntags:\n  - a\n  - b",
+			schema:   schema,
+			want:     `{"score":4.5,"tags":["a","b"],"title":"Report"}`,
+		},
+		{
+			name:     "code fence is stripped",
+			response: "```yaml\ntitle: Report\nscore: 3\ntags: []\n```",
+			schema:   schema,
+			want:     `{"score":3,"tags":[],"title":"Report"}`,
+		},
+		{
+			name:     "missing key",
+			response: "title: Report\ntags: []",
+			schema:   schema,
+			want:     `{"tags":[],"title":"Report"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "wrong type",
+			response: "title: Report\nscore: high\ntags: []",
+			schema:   schema,
+			want:     `{"score":"high","tags":[],"title":"Report"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "list of the wrong type",
+			response: "title: Report\nscore: 1\ntags:\n  - nested: map",
+			schema:   schema,
+			want:     `{"score":1,"tags":[{"nested":"map"}],"title":"Report"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "not yaml",
+			response: "title: [unclosed",
+			schema:   schema,
+			wantErr:  true,
+		},
+		{
+			name:     "example values only check shape",
+			response: "name: Ada",
+			schema:   "name: John Smith",
+			want:     `{"name":"Ada"}`,
+		},
+		{
+			name:     "free-form schema",
+			response: "anything: goes",
+			schema:   "",
+			want:     `{"anything":"goes"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStructured(tt.response, tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"vector-ai/model"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateTemplate checks a template's declared variables, their defaults, and that
+// its text only uses declared variables
+func ValidateTemplate(text string, variables []model.TemplateVariable) error {
+	seen := map[string]bool{}
+	sample := map[string]any{}
+
+	for _, variable := range variables {
+		if !variableName.MatchString(variable.Name) {
+			return fmt.Errorf("variable name %q may only hold letters, digits and underscores", variable.Name)
+		}
+		if seen[variable.Name] {
+			return fmt.Errorf("variable %s is declared twice", variable.Name)
+		}
+		seen[variable.Name] = true
+
+		zero, err := zeroValue(variable.Type)
+		if err != nil {
+			return fmt.Errorf("variable %s: %v", variable.Name, err)
+		}
+		sample[variable.Name] = zero
+
+		if variable.Default != nil {
+			if _, err := variableValue(variable, variable.Default); err != nil {
+				return fmt.Errorf("default of %v", err)
+			}
+		}
+	}
+
+	if len(variables) == 0 {
+		return nil // text is used as is
+	}
+
+	parsed, err := parseTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	// every branch and defined template, not only those a sample run takes
+	for _, tmpl := range parsed.Templates() {
+		if name, ok := undeclaredField(tmpl.Tree.Root, seen, true); !ok {
+			return fmt.Errorf("template uses an undeclared variable: %s", name)
+		}
+	}
+	if err := parsed.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("template does not run with its variables: %v", err)
+	}
+
+	return nil
+}
+
+// TemplateValues checks a query's values against a template's variables, filling in
+// defaults. Optional variables with neither are empty.
+func TemplateValues(variables []model.TemplateVariable, values map[string]any) (map[string]any, error) {
+	filled := map[string]any{}
+	declared := map[string]bool{}
+
+	for _, variable := range variables {
+		declared[variable.Name] = true
+
+		value, ok := values[variable.Name]
+		if !ok || value == nil {
+			if variable.Default == nil {
+				if variable.Required {
+					return nil, fmt.Errorf("variable %s is required", variable.Name)
+				}
+				filled[variable.Name] = ""
+				continue
+			}
+			value = variable.Default
+		}
+
+		value, err := variableValue(variable, value)
+		if err != nil {
+			return nil, err
+		}
+		filled[variable.Name] = value
+	}
+
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("template has no variable %s", name)
+		}
+	}
+
+	return filled, nil
+}
+
+// RenderTemplate fills validated values into a template's text. Templates without
+// variables are returned as is.
+func RenderTemplate(text string, variables []model.TemplateVariable, values map[string]any) (string, error) {
+	if len(variables) == 0 {
+		return text, nil
+	}
+
+	parsed, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered strings.Builder
+	if err := parsed.Execute(&rendered, values); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	parsed, err := template.New("instructions").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template text does not parse: %v", err)
+	}
+	return parsed, nil
+}
+
+// undeclaredField walks a template for fields of the top-level data that aren't
+// declared. Inside with and range dot is rebound, only $ fields refer to the data there.
+func undeclaredField(node parse.Node, declared map[string]bool, topLevel bool) (string, bool) {
+	check := func(children ...parse.Node) (string, bool) {
+		for _, child := range children {
+			if name, ok := undeclaredField(child, declared, topLevel); !ok {
+				return name, false
+			}
+		}
+		return "", true
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil { // a missing else
+			return check(n.Nodes...)
+		}
+	case *parse.ActionNode:
+		return check(n.Pipe)
+	case *parse.TemplateNode:
+		return check(n.Pipe)
+	case *parse.PipeNode:
+		if n != nil { // a template called without data
+			for _, cmd := range n.Cmds {
+				if name, ok := check(cmd); !ok {
+					return name, false
+				}
+			}
+		}
+	case *parse.CommandNode:
+		return check(n.Args...)
+	case *parse.ChainNode:
+		return check(n.Node)
+	case *parse.IfNode:
+		return check(n.Pipe, n.List, n.ElseList)
+	case *parse.WithNode:
+		if name, ok := check(n.Pipe, n.ElseList); !ok {
+			return name, false
+		}
+		return undeclaredField(n.List, declared, false)
+	case *parse.RangeNode:
+		if name, ok := check(n.Pipe, n.ElseList); !ok {
+			return name, false
+		}
+		return undeclaredField(n.List, declared, false)
+	case *parse.FieldNode:
+		if topLevel && !declared[n.Ident[0]] {
+			return n.Ident[0], false
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" && !declared[n.Ident[1]] {
+			return n.Ident[1], false
+		}
+	}
+
+	return "", true
+}
+
+// variableValue checks a value, as decoded from JSON, against a variable's type
+func variableValue(variable model.TemplateVariable, value any) (any, error) {
+	switch variable.Type {
+	case model.VariableString:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	case model.VariableNumber:
+		switch number := value.(type) {
+		case float64:
+			return number, nil
+		case int:
+			return float64(number), nil
+		case int64:
+			return float64(number), nil
+		}
+	case model.VariableBoolean:
+		if flag, ok := value.(bool); ok {
+			return flag, nil
+		}
+	case model.VariableDate:
+		if text, ok := value.(string); ok {
+			if _, err := time.Parse(time.DateOnly, text); err == nil {
+				return text, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("variable %s must be a %s, got %v", variable.Name, variable.Type, value)
+}
+
+func zeroValue(variableType string) (any, error) {
+	switch variableType {
+	case model.VariableString:
+		return "", nil
+	case model.VariableNumber:
+		return float64(0), nil
+	case model.VariableBoolean:
+		return false, nil
+	case model.VariableDate:
+		return "2006-01-02", nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", variableType)
+	}
+}
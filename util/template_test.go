@@ -0,0 +1,107 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+	"vector-ai/model"
+)
+
+func TestTemplateValues(t *testing.T) {
+	variables := []model.TemplateVariable{
+		{Name: "name", Type: model.VariableString, Required: true},
+		{Name: "count", Type: model.VariableNumber, Default: float64(3)},
+		{Name: "formal", Type: model.VariableBoolean},
+		{Name: "due", Type: model.VariableDate},
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]any
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:   "defaults and empty optionals",
+			values: map[string]any{"name": "Ada"},
+			want:   map[string]any{"name": "Ada", "count": float64(3), "formal": "", "due": ""},
+		},
+		{
+			name:   "all given",
+			values: map[string]any{"name": "Ada", "count": 5, "formal": true, "due": "2026-10-19"},
+			want:   map[string]any{"name": "Ada", "count": float64(5), "formal": true, "due": "2026-10-19"},
+		},
+		{
+			name:   "null takes the default",
+			values: map[string]any{"name": "Ada", "count": nil},
+			want:   map[string]any{"name": "Ada", "count": float64(3), "formal": "", "due": ""},
+		},
+		{
+			name:    "required missing",
+			values:  map[string]any{},
+			wantErr: true,
+		},
+		{
+			name:    "wrong type",
+			values:  map[string]any{"name": "Ada", "count": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "bad date",
+			values:  map[string]any{"name": "Ada", "due": "19/10/2026"},
+			wantErr: true,
+		},
+		{
+			name:    "undeclared",
+			values:  map[string]any{"name": "Ada", "other": "x"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TemplateValues(variables, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	variables := []model.TemplateVariable{
+		{Name: "name", Type: model.VariableString},
+		{Name: "formal", Type: model.VariableBoolean},
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		variables []model.TemplateVariable
+		wantErr   bool
+	}{
+		{name: "no variables is plain text", text: "{{ not a template", variables: nil},
+		{name: "declared", text: "Hello {{.name}}", variables: variables},
+		{name: "undeclared", text: "Hello {{.other}}", variables: variables, wantErr: true},
+		{name: "undeclared in an untaken branch", text: "{{if .formal}}Dear {{.title}}{{end}}", variables: variables, wantErr: true},
+		{name: "undeclared in else", text: "{{if .formal}}Dear{{else}}{{.nick}}{{end}}", variables: variables, wantErr: true},
+		{name: "dot rebound by with", text: "{{with .name}}Hi {{.}}{{end}}", variables: variables},
+		{name: "undeclared through $", text: "{{with .name}}{{$.other}}{{end}}", variables: variables, wantErr: true},
+		{name: "does not parse", text: "{{.name", variables: variables, wantErr: true},
+		{name: "bad name", text: "", variables: []model.TemplateVariable{{Name: "first name", Type: model.VariableString}}, wantErr: true},
+		{name: "declared twice", text: "", variables: []model.TemplateVariable{variables[0], variables[0]}, wantErr: true},
+		{name: "unknown type", text: "", variables: []model.TemplateVariable{{Name: "x", Type: "list"}}, wantErr: true},
+		{name: "bad default", text: "", variables: []model.TemplateVariable{{Name: "x", Type: model.VariableNumber, Default: "one"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(tt.text, tt.variables)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
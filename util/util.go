@@ -2,10 +2,13 @@ package util
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 	"vector-ai/model"
 
@@ -98,6 +101,43 @@ func GetAdjacentIndeces(index int64, adjacentRange int64) []int64 {
 	return indeces
 }
 
+// JoinChunks concatenates consecutive chunks, dropping the text the splitter
+// repeated between neighbours (ChunkOverlap)
+func JoinChunks(chunks []string) string {
+	minOverlap := 10 // shorter matches are likely coincidental
+
+	joined := ""
+	for i, chunk := range chunks {
+		if i == 0 {
+			joined = chunk
+			continue
+		}
+
+		overlap := 0
+		for k := min(len(joined), len(chunk)); k >= minOverlap; k-- {
+			if strings.HasSuffix(joined, chunk[:k]) {
+				overlap = k
+				break
+			}
+		}
+
+		if overlap > 0 {
+			joined += chunk[overlap:]
+		} else {
+			joined += "\n" + chunk
+		}
+	}
+
+	return joined
+}
+
+// ContentHash returns the hex sha256 of a document's text with whitespace
+// collapsed, so re-exports that only differ in line breaks still match
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
 func MapFolderIds(folderSyncs []model.DriveFolderSync) []string {
 	folderIds := []string{}
 	for _, folder := range folderSyncs {
@@ -0,0 +1,45 @@
+package util
+
+import "testing"
+
+func TestJoinChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{
+			name:   "no chunks",
+			chunks: []string{},
+			want:   "",
+		},
+		{
+			name:   "single chunk",
+			chunks: []string{"only chunk"},
+			want:   "only chunk",
+		},
+		{
+			name:   "overlap is dropped",
+			chunks: []string{"the quick brown fox jumps", "brown fox jumps over the lazy dog"},
+			want:   "the quick brown fox jumps over the lazy dog",
+		},
+		{
+			name:   "short matches are coincidental",
+			chunks: []string{"ends with the", "the start"},
+			want:   "ends with the\nthe start",
+		},
+		{
+			name:   "no overlap joins on a newline",
+			chunks: []string{"first paragraph", "second paragraph", "third paragraph"},
+			want:   "first paragraph\nsecond paragraph\nthird paragraph",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JoinChunks(tt.chunks); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}